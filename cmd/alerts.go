@@ -0,0 +1,53 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// alertsState filters alerts by their state
+var alertsState string
+
+// alertsCmd represents the alerts command
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "Get a list of firing and pending alerts",
+	Long:  `Get a list of firing and pending alerts, including their labels, when they became active and their current value.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch alertsState {
+		case "", "firing", "pending":
+		default:
+			errlog.Fatalf("invalid state %q, must be one of firing or pending\n", alertsState)
+		}
+		result, err := pql.AlertsQuery()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		var r writer.AlertsResult = result
+		r = r.Filter(alertsState)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	alertsCmd.Flags().StringVar(&alertsState, "state", "", "only show alerts in the provided state. Options: firing,pending")
+	rootCmd.AddCommand(alertsCmd)
+}
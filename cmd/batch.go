@@ -0,0 +1,189 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+
+	"github.com/nalbury/promql-cli/pkg/batch"
+	"github.com/nalbury/promql-cli/pkg/promql"
+)
+
+// cmd line args
+var (
+	// batchConcurrency is the number of queries of a batch file to run at once
+	batchConcurrency int
+	// batchOutputDir is the directory each result is written to, instead of stdout
+	batchOutputDir string
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch [batch_file]",
+	Short: "Run a file of named queries",
+	Long: `Run a YAML file of named queries concurrently, writing each result to stdout under its name or to its own file.
+
+Each query can set its own time, start, end, step, output, no-headers and template vars, falling back to the file's defaults and then the command line flags.
+Queries with a start are run as range queries. Results are written in the order of the file, regardless of which query finishes first.
+
+queries:
+  - name: cpu
+    query: sum(rate(node_cpu_seconds_total{mode!="idle"}[5m])) by (instance)
+  - name: memory
+    query: sum(node_memory_MemAvailable_bytes) by (instance)
+    start: 1w
+    step: 1h
+    output: csv
+    file: memory-weekly.csv`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := batch.Load(args[0])
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		if batchConcurrency < 1 {
+			errlog.Fatalln("--concurrency must be at least 1")
+		}
+		if failed := runBatch(f, batchConcurrency, batchOutputDir); failed > 0 {
+			errlog.Fatalf("%d of %d queries failed\n", failed, len(f.Queries))
+		}
+	},
+}
+
+// batchResult is the rendered result of a single query of a batch
+type batchResult struct {
+	buf      bytes.Buffer
+	warnings v1.Warnings
+	// problems are the lint problems found in the query before it was run
+	problems []string
+	err      error
+}
+
+// runBatch runs the queries of f with at most concurrency running at once, and writes each result
+// as soon as it and every query before it have finished. It returns the number of queries that failed.
+func runBatch(f batch.File, concurrency int, dir string) int {
+	results := make([]batchResult, len(f.Queries))
+	done := make([]chan struct{}, len(f.Queries))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, q := range f.Queries {
+		done[i] = make(chan struct{})
+		wg.Add(1)
+		go func(i int, q batch.Query) {
+			defer wg.Done()
+			defer close(done[i])
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			p, err := batchPromQL(q)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			if results[i].problems, results[i].err = checkQuery(p, q.Query); results[i].err != nil {
+				return
+			}
+			results[i].buf, results[i].warnings, results[i].err = renderQuery(p, q.Query)
+		}(i, q)
+	}
+
+	failed := 0
+	for i, q := range f.Queries {
+		<-done[i]
+		r := results[i]
+		for _, problem := range r.problems {
+			errlog.Printf("Warning for %s: %s\n", q.Name, problem)
+		}
+		if len(r.warnings) > 0 {
+			errlog.Printf("Warnings for %s: %v\n", q.Name, r.warnings)
+		}
+		if r.err == nil {
+			r.err = writeBatchResult(q, r.buf, dir)
+		}
+		if r.err != nil {
+			errlog.Printf("Error running %s: %v\n", q.Name, r.err)
+			failed++
+		}
+	}
+	wg.Wait()
+	return failed
+}
+
+// batchPromQL returns a copy of the global config with the settings of q applied, sharing its client and cache
+func batchPromQL(q batch.Query) (*promql.PromQL, error) {
+	p := pql
+	if q.Time != "" {
+		t, err := parseTime(q.Time)
+		if err != nil {
+			return nil, err
+		}
+		p.Time = t
+	}
+	if q.Start != "" {
+		p.Start = q.Start
+	}
+	if q.End != "" {
+		p.End = q.End
+	}
+	if q.Step != "" {
+		p.Step = q.Step
+	}
+	if q.Output != "" {
+		p.Output = q.Output
+	}
+	p.NoHeaders = p.NoHeaders || q.NoHeaders
+	if len(q.Vars) > 0 {
+		p.Vars = make(map[string]string, len(pql.Vars)+len(q.Vars))
+		for k, v := range pql.Vars {
+			p.Vars[k] = v
+		}
+		for k, v := range q.Vars {
+			p.Vars[k] = v
+		}
+	}
+	return &p, nil
+}
+
+// writeBatchResult writes the result of q to its file if it has one or dir is set, or to stdout under its name otherwise
+func writeBatchResult(q batch.Query, buf bytes.Buffer, dir string) error {
+	if dir == "" && q.File == "" {
+		fmt.Printf("# %s\n%s\n\n", q.Name, strings.TrimRight(buf.String(), "\n"))
+		return nil
+	}
+	path := batch.ResultPath(dir, q)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s to %s\n", q.Name, path)
+	return nil
+}
+
+func init() {
+	batchCmd.Flags().IntVar(&batchConcurrency, "concurrency", 4, "number of queries to run at once")
+	batchCmd.Flags().StringVar(&batchOutputDir, "output-dir", "", "write each result to its own file in this directory, named after the query unless it sets a file")
+	rootCmd.AddCommand(batchCmd)
+}
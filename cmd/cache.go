@@ -0,0 +1,99 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nalbury/promql-cli/pkg/cache"
+	"github.com/nalbury/promql-cli/pkg/util"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the query result cache",
+	Long: `Manage the on-disk cache of query results used with --cache.
+
+Range query results are cached by host, query and step, so later queries over an overlapping range only fetch the samples they haven't seen yet.
+Series and metadata results are reused until they're older than --cache-ttl.`,
+}
+
+// cacheStatsCmd represents the cache stats command
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number and size of cached results",
+	Long:  `Show the number, size and age of cached results of each kind`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := openCache()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.CacheStatsResult(stats)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached results",
+	Long:  `Remove all cached results`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := openCache()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		removed, err := c.Clear()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		fmt.Printf("Removed %d cached results from %s.\n", removed, c.Dir)
+	},
+}
+
+// openCache opens the cache in the directory set by --cache-dir, or the default cache directory
+func openCache() (*cache.Cache, error) {
+	ttl, err := util.ParseDuration(viper.GetString("cache-ttl"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cache ttl, %v", err)
+	}
+	dir := viper.GetString("cache-dir")
+	if dir == "" {
+		if dir, err = cache.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return cache.New(dir, ttl)
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
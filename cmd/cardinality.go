@@ -0,0 +1,48 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// cardinalityLimit is the number of entries to return for each list of stats
+var cardinalityLimit int
+
+// cardinalityCmd represents the cardinality command
+var cardinalityCmd = &cobra.Command{
+	Use:   "cardinality",
+	Short: "Get the series cardinality stats of the prometheus TSDB",
+	Long:  `Get the series cardinality stats of the prometheus TSDB head block, including the top metrics by series count, top label names by value count and memory usage, and top label value pairs by series count.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result, err := pql.CardinalityQuery(cardinalityLimit)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.CardinalityResult{TSDBResult: result}
+		r = r.Limit(cardinalityLimit)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	cardinalityCmd.Flags().IntVar(&cardinalityLimit, "limit", 10, "number of entries to return for each list of stats (prometheus v2.42+ is required for more than 10)")
+	rootCmd.AddCommand(cardinalityCmd)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/nalbury/promql-cli/pkg/promql"
+	"github.com/nalbury/promql-cli/pkg/util"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+// compareTimes returns the times to evaluate a query at when comparing results, keyed by the name of each evaluation.
+// These are either every --time flag, or the first --time flag followed by each --compare offset before it.
+func compareTimes(base time.Time) ([]string, []time.Time, error) {
+	if len(compareOffsets) == 0 {
+		times := make([]time.Time, len(timeStrs))
+		for i, s := range timeStrs {
+			if i == 0 {
+				times[i] = base
+				continue
+			}
+			t, err := parseTime(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			times[i] = t
+		}
+		return timeStrs, times, nil
+	}
+	if len(timeStrs) > 1 {
+		return nil, nil, fmt.Errorf("--compare can't be used with multiple --time flags, provide one or the other")
+	}
+	names := []string{timeStrs[0]}
+	times := []time.Time{base}
+	for _, o := range compareOffsets {
+		d, err := util.ParseDuration(o)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse compare offset, %v", err)
+		}
+		names = append(names, timeStrs[0]+"-"+o)
+		times = append(times, base.Add(-d))
+	}
+	return names, times, nil
+}
+
+// runCompare evaluates the query at each of the compared times and writes a single table with a column per evaluation
+func runCompare(p *promql.PromQL, q string) error {
+	if err := validateQuery(p, q); err != nil {
+		return err
+	}
+	names, times, err := compareTimes(p.Time)
+	if err != nil {
+		return err
+	}
+	var r writer.CompareResult
+	for i, t := range times {
+		e := *p
+		e.Time = t
+		result, warnings, err := e.InstantQuery(q)
+		if len(warnings) > 0 {
+			errlog.Printf("Warnings: %v\n", warnings)
+		}
+		if err != nil {
+			return err
+		}
+		var v model.Vector
+		switch result := result.(type) {
+		case model.Vector:
+			v = result
+		case *model.Scalar:
+			v = model.Vector{{Metric: model.Metric{}, Value: result.Value, Timestamp: result.Timestamp}}
+		default:
+			return fmt.Errorf("unsupported result type %s for comparison, only instant vectors and scalars can be compared", result.Type())
+		}
+		r = append(r, writer.Evaluation{Name: names[i], Vector: v})
+	}
+	return writer.WriteInstant(&r, p.Output, p.NoHeaders)
+}
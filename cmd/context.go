@@ -0,0 +1,201 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/nalbury/promql-cli/pkg/config"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+// contextCmd represents the context command
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage named server contexts",
+	Long: `Manage the named server contexts defined in the config file.
+
+Each context can set its own host, auth, TLS config and default step, output and timeout:
+
+current-context: prod
+contexts:
+  prod:
+    host: https://prometheus.prod.example.com
+    auth-type: Bearer
+    auth-credentials-file: /path/to/token
+    step: 5m
+  staging:
+    host: https://prometheus.staging.example.com`,
+}
+
+// contextListCmd represents the context list command
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the contexts defined in the config file",
+	Long:  `List the contexts defined in the config file`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := loadConfigFile()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		current := currentContext(f)
+		var r writer.ContextsResult
+		for _, name := range f.Names() {
+			host, _ := f.Contexts[name]["host"].(string)
+			r = append(r, writer.Context{Name: name, Host: host, Current: name == current})
+		}
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+// contextUseCmd represents the context use command
+var contextUseCmd = &cobra.Command{
+	Use:   "use [context_name]",
+	Short: "Set the current context in the config file",
+	Long:  `Set the current context in the config file`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := configFilePath()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		if err := config.SetCurrentContext(path, args[0]); err != nil {
+			errlog.Fatalln(err)
+		}
+		fmt.Printf("Switched to context %q.\n", args[0])
+	},
+}
+
+// contextShowCmd represents the context show command
+var contextShowCmd = &cobra.Command{
+	Use:   "show [context_name]",
+	Short: "Show the settings of a context",
+	Long:  `Show the settings of a context. If no context name is provided, the current context is shown. Credentials are redacted.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := loadConfigFile()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		name := currentContext(f)
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name == "" {
+			errlog.Fatalln("no current context is set, run promql context use [context_name] to set one")
+		}
+		c, ok := f.Contexts[name]
+		if !ok {
+			errlog.Fatalf("context %s not found in %s\n", name, f.Path)
+		}
+		settings := config.Flatten(c)
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		r := writer.SettingsResult{{Name: "name", Value: name}}
+		for _, k := range keys {
+			v := settings[k]
+			if k == "auth-credentials" {
+				v = "<redacted>"
+			}
+			r = append(r, writer.Setting{Name: k, Value: v})
+		}
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+// configFilePath returns the path of the config file in use, or the default location if none was found
+func configFilePath() (string, error) {
+	if f := viper.ConfigFileUsed(); f != "" {
+		return f, nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".promql-cli.yaml"), nil
+}
+
+// loadConfigFile parses the contexts from the config file in use
+func loadConfigFile() (config.File, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return config.File{}, err
+	}
+	return config.Load(path)
+}
+
+// currentContext returns the context selected by the --context flag, falling back to the current-context of the config file
+func currentContext(f config.File) string {
+	if c := viper.GetString("context"); c != "" {
+		return c
+	}
+	return f.CurrentContext
+}
+
+// applyContext replaces the settings read from the config file with the resolved settings of the selected context.
+// Flags and env vars still take precedence over anything set in a context.
+func applyContext() error {
+	requested := viper.GetString("context")
+	f, err := loadConfigFile()
+	if err != nil {
+		// Config files we can't parse for contexts are only an error if a context was asked for
+		if requested != "" {
+			return err
+		}
+		return nil
+	}
+	name := currentContext(f)
+	if name == "" {
+		return nil
+	}
+	settings, err := f.Resolve(name)
+	if err != nil {
+		if requested != "" {
+			return err
+		}
+		errlog.Printf("Ignoring current-context: %v\n", err)
+		return nil
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("error loading context %s: %v", name, err)
+	}
+	viper.SetConfigType("json")
+	return viper.ReadConfig(bytes.NewReader(b))
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextShowCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nalbury/promql-cli/pkg/lint"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+// costMaxSamples is the estimated number of samples above which the cost command warns about a query
+var costMaxSamples int64
+
+// costCmd represents the cost command
+var costCmd = &cobra.Command{
+	Use:   "cost [query_string]",
+	Short: "Estimate the number of samples a query would read",
+	Long: `Estimate the number of samples a query would read before running it, by counting the series matched by each of its vector selectors.
+
+Range queries are estimated over the --start/--end range and step, and instant queries at --time. Each selector reads a sample per series
+for every step, or every sample within its range for range vector selectors, assuming the --scrape-interval. Subqueries multiply the
+evaluations of the selectors within them by their number of steps.
+
+If the estimate is above --max-samples a warning is printed and the command exits with an error, so it can guard queries in scripts.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		q, err := pql.Expand(query)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		expr, err := lint.Parse(q)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		cost, err := pql.CostQuery(expr)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.CostResult{Cost: cost}
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+		if costMaxSamples > 0 && cost.Samples > costMaxSamples {
+			errlog.Fatalf("Warning: this query would read about %s samples, more than --max-samples %d\n", humanCount(cost.Samples), costMaxSamples)
+		}
+	},
+}
+
+// humanCount formats large counts in millions or billions, e.g. 12.4 million
+func humanCount(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f billion", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f million", float64(n)/1e6)
+	}
+	return fmt.Sprint(n)
+}
+
+func init() {
+	costCmd.Flags().Int64Var(&costMaxSamples, "max-samples", 1000000, "warn and exit with an error if the query would read more samples than this, 0 to never warn")
+	addQueryFileFlag(costCmd)
+	rootCmd.AddCommand(costCmd)
+}
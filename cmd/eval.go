@@ -0,0 +1,83 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nalbury/promql-cli/pkg/local"
+)
+
+// evalDataFiles are the files of series that the eval command loads
+var evalDataFiles []string
+
+// evalCmd represents the eval command
+var evalCmd = &cobra.Command{
+	Use:   "eval --data file [query_string]",
+	Short: "Evaluate a query against series loaded from local files, without a server",
+	Long: `Evaluate a query locally with the Prometheus query engine, against series loaded from --data files instead of a server.
+Results are written the same way as queries against a server, so --start, --time, --step and --output all work as usual.
+
+Data files can use the promtool series notation of rule unit tests, e.g.
+
+  load 1m
+    http_requests_total{job="api", code="200"} 0+60x60
+    http_requests_total{job="api", code="500"} 0+6x60
+
+which is placed so the longest series ends at --time, or --end for range queries. Files can also be Prometheus text exposition
+or OpenMetrics, e.g. captured from a /metrics endpoint, where samples without a timestamp are placed at the same time.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if len(evalDataFiles) == 0 {
+			errlog.Fatalln("at least one --data file is required")
+		}
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		// Series without timestamps are anchored to the time the query is evaluated at, so they're in range of it
+		anchor := pql.Time
+		if pql.Start != "" {
+			t, err := parseTime(pql.End)
+			if err != nil {
+				errlog.Fatalf("error parsing range end time, %v\n", err)
+			}
+			anchor = t
+		}
+		s, err := local.Open(evalDataFiles, anchor, pql.TimeoutDuration)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		p := pql
+		p.Client = s.API()
+		// Local results are always fresh, and there's no server limit to split queries for
+		p.Cache = nil
+		p.Split = ""
+		// There are no exemplars in the data files to mark on graphs
+		p.Exemplars = false
+		err = runQuery(&p, query)
+		s.Close()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	evalCmd.Flags().StringArrayVar(&evalDataFiles, "data", nil, "file of series to evaluate the query against, in promtool series notation, text exposition or OpenMetrics format. Can be repeated")
+	addQueryFileFlag(evalCmd)
+	rootCmd.AddCommand(evalCmd)
+}
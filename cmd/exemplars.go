@@ -0,0 +1,50 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// exemplarsCmd represents the exemplars command
+var exemplarsCmd = &cobra.Command{
+	Use:   "exemplars [query_string]",
+	Short: "Get the exemplars of the series selected by a query",
+	Long: `Get the exemplars of the series selected by a query over the --start/--end range, along with their trace IDs and values.
+
+To mark exemplars on the graph of a range query instead, run the query with --exemplars.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		result, err := pql.ExemplarsQuery(query)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.ExemplarsResult(result)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	addQueryFileFlag(exemplarsCmd)
+	rootCmd.AddCommand(exemplarsCmd)
+}
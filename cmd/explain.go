@@ -0,0 +1,58 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/nalbury/promql-cli/pkg/lint"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain [query_string]",
+	Short: "Print the parsed expression tree of a query",
+	Long: `Parse a query locally and print its expression tree, with a line for each aggregation, binary operator, function call, selector and literal along with the type of value it returns.
+
+Binary operators show how the series of both sides are matched, including on/ignoring labels, group_left/group_right and the resulting cardinality,
+which helps when debugging vector matching errors. Selectors show their matchers, range, offset and @ modifiers.
+Use --output json for the full tree with every field of each node.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		q, err := pql.Expand(query)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		expr, err := lint.Parse(q)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.ExplainResult{Expr: expr}
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	addQueryFileFlag(explainCmd)
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nalbury/promql-cli/pkg/format"
+	"github.com/nalbury/promql-cli/pkg/util"
+)
+
+// cmd line args
+var (
+	// fmtInPlace rewrites query files with their formatted query instead of printing it
+	fmtInPlace bool
+	// fmtColor highlights the formatted query with ANSI colors
+	fmtColor bool
+)
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [query_string]",
+	Short: "Pretty-format a query",
+	Long: `Parse a query and print it pretty-formatted, with nested aggregations and binary operators that don't fit on one line split over indented lines.
+
+With --in-place, the arguments are query files that are rewritten with their formatted query. Comment lines at the top of a file are kept.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fmtInPlace {
+			return nil
+		}
+		return cobra.MaximumNArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if fmtInPlace {
+			if fmtColor {
+				errlog.Fatalln("--color can't be used with --in-place")
+			}
+			files := args
+			if queryFile != "" && queryFile != util.StdinPath {
+				files = append(files, queryFile)
+			}
+			if len(files) == 0 {
+				errlog.Fatalln("--in-place requires at least one query file")
+			}
+			failed := 0
+			for _, f := range files {
+				if err := formatFile(f); err != nil {
+					errlog.Printf("%s: %v\n", f, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				errlog.Fatalf("%d of %d files could not be formatted\n", failed, len(files))
+			}
+			return
+		}
+		resolveQuery(args)
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		formatted, err := format.Format(query)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		if fmtColor {
+			formatted = format.Highlight(formatted)
+		}
+		fmt.Println(formatted)
+	},
+}
+
+// formatFile rewrites the query file at path with its formatted query, leaving it untouched if it's already formatted
+func formatFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	formatted, err := format.FormatFile(string(b))
+	if err != nil {
+		return err
+	}
+	if formatted == string(b) {
+		return nil
+	}
+	return os.WriteFile(path, []byte(formatted), info.Mode())
+}
+
+func init() {
+	fmtCmd.Flags().BoolVar(&fmtInPlace, "in-place", false, "rewrite the query files given as arguments with their formatted query instead of printing it")
+	fmtCmd.Flags().BoolVar(&fmtColor, "color", false, "highlight the formatted query with ANSI colors")
+	addQueryFileFlag(fmtCmd)
+	rootCmd.AddCommand(fmtCmd)
+}
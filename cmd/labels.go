@@ -20,13 +20,36 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// labelMatchers are the series selectors used to narrow label name and value queries
+var labelMatchers []string
+
 // labelsCmd represents the labels command
 var labelsCmd = &cobra.Command{
 	Use:   "labels [query_string]",
 	Short: "Get a list of all labels for a given query",
-	Long:  `Get a list of all labels for a given query`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Get a list of all labels for a given query.
+
+If no query is provided, label names are fetched from the labels API instead, optionally limited to series matching the --match selectors and --start/--end range.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if query == "" {
+			result, warnings, err := pql.LabelNamesQuery(labelMatchers)
+			if len(warnings) > 0 {
+				errlog.Printf("Warnings: %v\n", warnings)
+			}
+			if err != nil {
+				errlog.Fatalln(err)
+			}
+			var r writer.LabelNamesResult = result
+			if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+				errlog.Fatalln(err)
+			}
+			return
+		}
+		if len(labelMatchers) > 0 {
+			errlog.Fatalln("--match can't be used with a query string, provide one or the other")
+		}
 		result, warnings, err := pql.LabelsQuery(query)
 		if len(warnings) > 0 {
 			errlog.Printf("Warnings: %v\n", warnings)
@@ -42,6 +65,31 @@ var labelsCmd = &cobra.Command{
 	},
 }
 
+// labelValuesCmd represents the label-values command
+var labelValuesCmd = &cobra.Command{
+	Use:   "label-values [label_name]",
+	Short: "Get a list of all values for a label",
+	Long:  `Get a list of all values for a label, optionally limited to series matching the --match selectors and --start/--end range.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		result, warnings, err := pql.LabelValuesQuery(args[0], labelMatchers)
+		if len(warnings) > 0 {
+			errlog.Printf("Warnings: %v\n", warnings)
+		}
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		r := writer.LabelValuesResult(result)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
 func init() {
+	labelsCmd.Flags().StringArrayVar(&labelMatchers, "match", nil, "series selector to limit the label names returned, can be repeated (e.g. --match 'up{job=\"node\"}')")
+	labelValuesCmd.Flags().StringArrayVar(&labelMatchers, "match", nil, "series selector to limit the label values returned, can be repeated (e.g. --match 'up{job=\"node\"}')")
+	addQueryFileFlag(labelsCmd)
 	rootCmd.AddCommand(labelsCmd)
+	rootCmd.AddCommand(labelValuesCmd)
 }
@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/nalbury/promql-cli/pkg/lint"
+	"github.com/nalbury/promql-cli/pkg/promql"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint [query_string]",
+	Short: "Check a query for syntax errors and common mistakes",
+	Long: `Parse a query locally and check it for common mistakes, such as rate() over a gauge, delta() over a counter,
+or histogram_quantile() over buckets that have had their le label aggregated away.
+
+Metric types are fetched from the metadata API. If they can't be fetched, only the checks that don't need them are run.
+Each problem is printed with a caret under the offending part of the query, and the command exits with an error if any are found.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
+		if query == "" {
+			errlog.Fatalln("a query is required, provide one as an argument, with --file or on stdin")
+		}
+		q, err := pql.Expand(query)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		expr, err := lint.Parse(q)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		meta, err := queryMetadata(&pql, expr)
+		if err != nil {
+			errlog.Printf("Unable to fetch metric types, skipping the checks that need them: %v\n", err)
+		}
+		problems := lint.Check(expr, meta)
+		for i, p := range problems {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(lint.Caret(q, p.Pos, p.Message))
+		}
+		switch len(problems) {
+		case 0:
+		case 1:
+			errlog.Fatalln("1 problem found")
+		default:
+			errlog.Fatalf("%d problems found\n", len(problems))
+		}
+	},
+}
+
+// queryMetadata fetches the metadata of the metrics of a parsed query, used to check it with lint.Check
+func queryMetadata(p *promql.PromQL, expr parser.Expr) (map[string][]v1.Metadata, error) {
+	meta := make(map[string][]v1.Metadata)
+	for _, name := range lint.MetadataNames(expr) {
+		result, err := p.MetaQuery(name)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range result {
+			meta[k] = v
+		}
+	}
+	return meta, nil
+}
+
+func init() {
+	addQueryFileFlag(lintCmd)
+	rootCmd.AddCommand(lintCmd)
+}
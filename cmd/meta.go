@@ -27,7 +27,11 @@ var metaCmd = &cobra.Command{
 	Long:  "Get the type and help metadata for a metric",
 	Run: func(cmd *cobra.Command, args []string) {
 		var r writer.MetaResult
-		result, err := pql.MetaQuery(query)
+		var metric string
+		if len(args) > 0 {
+			metric = args[0]
+		}
+		result, err := pql.MetaQuery(metric)
 		if err != nil {
 			errlog.Fatalln(err)
 		}
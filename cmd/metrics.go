@@ -26,6 +26,7 @@ var metricsCmd = &cobra.Command{
 	Short: "Get a list of prometheus metric names matching the provided query",
 	Long:  `Get a list of prometheus metric names matching the provided query. If no query is provided, all metric names will be returned.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		resolveQuery(args)
 		var r writer.SeriesResult
 		if query == "" {
 			query = `{job=~".+"}`
@@ -46,5 +47,6 @@ var metricsCmd = &cobra.Command{
 }
 
 func init() {
+	addQueryFileFlag(metricsCmd)
 	rootCmd.AddCommand(metricsCmd)
 }
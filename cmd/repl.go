@@ -0,0 +1,240 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+
+	"github.com/nalbury/promql-cli/pkg/promql"
+	"github.com/nalbury/promql-cli/pkg/repl"
+	"github.com/nalbury/promql-cli/pkg/writer"
+)
+
+const replHelp = `Enter a promql query to run it, or one of the following commands:
+  .start [value]    set the range start (no value switches back to instant queries)
+  .end <value>      set the range end
+  .step <value>     set the range step (auto derives it from the range)
+  .time <value>     set the time for instant queries
+  .output [format]  set the output format (no value resets to the default)
+  .settings         print the current settings
+  .help             print this help
+  .quit             exit the shell`
+
+// replTimeSet is true once the time for instant queries has been set with .time, which stops it following the clock
+var replTimeSet bool
+
+// replCmd represents the repl command
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive promql shell",
+	Long:  `Start an interactive promql shell with query history and tab completion of metric names, label names and label values.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runRepl()
+	},
+}
+
+// runRepl reads queries from the terminal until the user exits, running each with our global config
+func runRepl() {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(repl.NewCompleter(&replSource{p: &pql}).Complete)
+
+	historyFile, err := replHistoryFile()
+	if err != nil {
+		errlog.Printf("Unable to load history: %v\n", err)
+	} else if f, err := os.Open(historyFile); err == nil {
+		if _, err := line.ReadHistory(f); err != nil {
+			errlog.Printf("Unable to load history: %v\n", err)
+		}
+		f.Close()
+	}
+
+	fmt.Println("Connected to " + pql.Host + ". Type .help for help.")
+	for {
+		// Keep the default completion window current while the shell sits at the prompt
+		refreshReplTime(&pql)
+		in, err := line.Prompt("promql> ")
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			break
+		}
+		if err != nil {
+			errlog.Println(err)
+			break
+		}
+		in = strings.TrimSpace(in)
+		if in == "" {
+			continue
+		}
+		line.AppendHistory(in)
+		if in == "exit" || in == "quit" || in == ".exit" || in == ".quit" {
+			break
+		}
+		if strings.HasPrefix(in, ".") {
+			if err := replCommand(&pql, in); err != nil {
+				errlog.Println(err)
+			}
+			continue
+		}
+		refreshReplTime(&pql)
+		if err := runQuery(&pql, in); err != nil {
+			errlog.Println(err)
+		}
+	}
+
+	if historyFile != "" {
+		f, err := os.Create(historyFile)
+		if err != nil {
+			errlog.Printf("Unable to save history: %v\n", err)
+			return
+		}
+		defer f.Close()
+		if _, err := line.WriteHistory(f); err != nil {
+			errlog.Printf("Unable to save history: %v\n", err)
+		}
+	}
+}
+
+// refreshReplTime re-parses --time so instant queries run at the time they're entered, rather than when the shell started.
+// Relative times like now-1h move along with the clock, and a time set with .time is left alone.
+func refreshReplTime(p *promql.PromQL) {
+	if replTimeSet {
+		return
+	}
+	if t, err := parseTime(timeStrs[0]); err == nil {
+		p.Time = t
+	}
+}
+
+// replCommand handles the dot commands used to change settings from within the shell
+func replCommand(p *promql.PromQL, in string) error {
+	fields := strings.Fields(in)
+	name, value := fields[0], strings.Join(fields[1:], " ")
+	switch name {
+	case ".help":
+		fmt.Println(replHelp)
+	case ".settings":
+		s := writer.SettingsResult{
+			{Name: "host", Value: p.Host},
+			{Name: "start", Value: p.Start},
+			{Name: "end", Value: p.End},
+			{Name: "step", Value: p.Step},
+			{Name: "time", Value: p.Time.Format(time.RFC3339)},
+			{Name: "output", Value: p.Output},
+		}
+		return writer.WriteInstant(&s, "", p.NoHeaders)
+	case ".start":
+		p.Start = value
+	case ".end":
+		if value == "" {
+			return fmt.Errorf("usage: .end <value>")
+		}
+		p.End = value
+	case ".step":
+		if value == "" {
+			return fmt.Errorf("usage: .step <value>")
+		}
+		p.Step = value
+	case ".time":
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		p.Time = t
+		replTimeSet = true
+	case ".output":
+		p.Output = value
+	default:
+		return fmt.Errorf("unknown command %s, type .help for help", name)
+	}
+	return nil
+}
+
+// replHistoryFile returns the location of the shell history file
+func replHistoryFile() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".promql_history"), nil
+}
+
+// replSource fetches completion candidates from prometheus
+type replSource struct {
+	p *promql.PromQL
+}
+
+// MetricNames returns all metric names, preferring the metadata endpoint and falling back to the series endpoint
+func (s *replSource) MetricNames() ([]string, error) {
+	meta, err := s.p.MetaQuery("")
+	if err == nil && len(meta) > 0 {
+		r := writer.MetaResult(meta)
+		return r.Metrics(), nil
+	}
+	series, _, err := s.p.SeriesQuery(`{__name__=~".+"}`)
+	if err != nil {
+		return nil, err
+	}
+	r := writer.SeriesResult(series)
+	return r.Metrics(), nil
+}
+
+// LabelNames returns the label names for the provided metric, or all label names if metric is empty
+func (s *replSource) LabelNames(metric string) ([]string, error) {
+	var matches []string
+	if metric != "" {
+		matches = []string{metric}
+	}
+	labels, _, err := s.p.LabelNamesQuery(matches)
+	return labels, err
+}
+
+// LabelValues returns the values of label for the provided metric, or for all series if metric is empty
+func (s *replSource) LabelValues(metric string, label string) ([]string, error) {
+	var matches []string
+	if metric != "" {
+		matches = []string{metric}
+	}
+	result, _, err := s.p.LabelValuesQuery(label, matches)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(result))
+	for _, v := range result {
+		values = append(values, string(v))
+	}
+	return values, nil
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+}
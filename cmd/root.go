@@ -16,6 +16,8 @@ limitations under the License.
 package cmd
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -25,9 +27,13 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
 
+	"github.com/nalbury/promql-cli/pkg/lint"
 	"github.com/nalbury/promql-cli/pkg/promql"
+	"github.com/nalbury/promql-cli/pkg/util"
 	"github.com/nalbury/promql-cli/pkg/writer"
 )
 
@@ -38,10 +44,18 @@ var errlog = log.New(os.Stderr, "", 0)
 var (
 	pql   promql.PromQL
 	query string
+	// queryFile is the path of a file to read the query from, or - for stdin
+	queryFile string
 	// This is placeholder for the initial flag value. We ultimately parse it into the TimeoutDuration paramater of our config
 	timeout int
-	// timeStr is a placeholder for the inital "time" flag value. We parse it to a time.Time for use in our queries
-	timeStr string
+	// timeStrs are placeholders for the inital "time" flag values. We parse the first to a time.Time for use in our queries
+	timeStrs []string
+	// compareOffsets are the offsets from --time to also evaluate an instant query at for comparison
+	compareOffsets []string
+	// varStrs are the key=value query template variables set with the --var flag
+	varStrs []string
+	// watchInterval is how often the query is re-run when the --watch flag is set
+	watchInterval time.Duration
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -49,8 +63,11 @@ var rootCmd = &cobra.Command{
 	Version: "v0.2.1",
 	Use:     "promql [query_string]",
 	Short:   "Query prometheus from the command line",
-	Long:    `Query prometheus from the command line for quick analysis.`,
-	Args:    cobra.ExactArgs(1),
+	Long: `Query prometheus from the command line for quick analysis. If no query is provided, an interactive shell is started.
+
+The query can also be read from a file with --file, or from stdin by passing - as the query or the file.
+Lines starting with # are ignored, so query files can be commented, and queries can span multiple lines.`,
+	Args: cobra.MaximumNArgs(1),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		pql.Auth.Type = viper.GetString("auth-type")
 		pql.Auth.Credentials = config.Secret(viper.GetString("auth-credentials"))
@@ -65,64 +82,215 @@ var rootCmd = &cobra.Command{
 
 		pql.Host = viper.GetString("host")
 		pql.Step = viper.GetString("step")
+		pql.Points = viper.GetInt("points")
+		pql.Split = viper.GetString("split")
+		pql.SplitConcurrency = viper.GetInt("split-concurrency")
+		if viper.GetBool("cache") {
+			c, err := openCache()
+			if err != nil {
+				errlog.Fatalln(err)
+			}
+			pql.Cache = c
+		}
 		pql.Output = viper.GetString("output")
 		// Convert our timeout flag into a time.Duration
 		timeout = viper.GetInt("timeout")
 		pql.TimeoutDuration = time.Duration(int64(timeout)) * time.Second
-		// Parse the timeStr from our --time flag if it was provided
-		pql.Time = time.Now()
-		if timeStr != "now" {
-			t, err := time.Parse(time.RFC3339, timeStr)
-			if err != nil {
-				errlog.Fatalln(err)
-			}
-			pql.Time = t
+		// Load the timezone used to parse times that don't include one
+		loc, err := time.LoadLocation(viper.GetString("timezone"))
+		if err != nil {
+			errlog.Fatalf("unable to load timezone, %v\n", err)
+		}
+		pql.Location = loc
+		// Merge the template variables of the --var flags over those set in the config file
+		vars, err := templateVars(viper.GetStringMapString("vars"), varStrs)
+		if err != nil {
+			errlog.Fatalln(err)
 		}
+		pql.Vars = vars
+		pql.ScrapeInterval, err = util.ParseDuration(viper.GetString("scrape-interval"))
+		if err != nil {
+			errlog.Fatalf("unable to parse scrape interval, %v\n", err)
+		}
+		// Parse the first --time flag if it was provided, any others are only used when comparing results
+		t, err := parseTime(timeStrs[0])
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		pql.Time = t
 		// Create and set client interface
 		cl, err := promql.CreateClientWithAuth(pql.Host, pql.Auth, pql.TLSConfig)
 		if err != nil {
 			errlog.Fatalln(err)
 		}
 		pql.Client = cl
-
-		// Set query string if present
-		// Downstream consumption of the query variable should handle any validation they need
-		if len(args) > 0 {
-			query = args[0]
-		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		// If we have a start time for the query, assume we're doing a range query
-		if pql.Start != "" {
-			result, warnings, err := pql.RangeQuery(query)
-			if len(warnings) > 0 {
-				errlog.Printf("Warnings: %v\n", warnings)
-			}
-			if err != nil {
-				errlog.Fatalln(err)
-			}
-			r := writer.RangeResult{Matrix: result}
-			if err := writer.WriteRange(&r, pql.Output, pql.NoHeaders); err != nil {
-				errlog.Println(err)
+		resolveQuery(args)
+		// Without a query string we drop into the interactive shell
+		if query == "" {
+			if watchInterval > 0 {
+				errlog.Fatalln("a query is required when using --watch")
 			}
-		} else {
-			// Run query
-			result, warnings, err := pql.InstantQuery(query)
-			if len(warnings) > 0 {
-				errlog.Printf("Warnings: %v\n", warnings)
+			runRepl()
+			return
+		}
+		if len(timeStrs) > 1 || len(compareOffsets) > 0 {
+			if watchInterval > 0 || pql.Start != "" {
+				errlog.Fatalln("comparing results at multiple times is only supported for instant queries without --watch")
 			}
-			if err != nil {
+			if err := runCompare(&pql, query); err != nil {
 				errlog.Fatalln(err)
 			}
-			// Write out result
-			r := writer.InstantResult{Vector: result}
-			if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			return
+		}
+		if watchInterval > 0 {
+			// Validate once up front, so problems are printed before the screen is taken over
+			if err := validateQuery(&pql, query); err != nil {
 				errlog.Fatalln(err)
 			}
+			runWatch(&pql, query, watchInterval)
+			return
+		}
+		if err := runQuery(&pql, query); err != nil {
+			errlog.Fatalln(err)
 		}
 	},
 }
 
+// addQueryFileFlag adds the --file flag to a command that takes a query
+func addQueryFileFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&queryFile, "file", "f", "", "read the query from a file instead of the command line, or - for stdin. Lines starting with # are ignored")
+}
+
+// resolveQuery sets the query of a command that takes one, either from the query file, stdin if the argument is -, or the first argument.
+// Downstream consumption of the query variable should handle any validation they need
+func resolveQuery(args []string) {
+	switch {
+	case queryFile != "":
+		if len(args) > 0 {
+			errlog.Fatalln("provide either a query string or --file, not both")
+		}
+		q, err := util.ReadQuery(queryFile, os.Stdin)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		query = q
+	case len(args) > 0 && args[0] == util.StdinPath:
+		q, err := util.ReadQuery(util.StdinPath, os.Stdin)
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		query = q
+	case len(args) > 0:
+		query = args[0]
+	}
+}
+
+// runQuery validates and executes the query with the provided config and writes the result to stdout
+func runQuery(p *promql.PromQL, q string) error {
+	if err := validateQuery(p, q); err != nil {
+		return err
+	}
+	buf, warnings, err := renderQuery(p, q)
+	if len(warnings) > 0 {
+		errlog.Printf("Warnings: %v\n", warnings)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(buf.String())
+	return nil
+}
+
+// renderQuery executes the query with the provided config and returns the formatted result.
+// It doesn't validate the query, callers check it first with validateQuery or checkQuery.
+func renderQuery(p *promql.PromQL, q string) (bytes.Buffer, v1.Warnings, error) {
+	// If we have a start time for the query, assume we're doing a range query
+	if p.Start != "" {
+		// Graphs plot one point per column, so auto steps are sized to fit the terminal unless a point count was set
+		if p.Points == 0 && p.Output == "" {
+			if dim, err := util.TerminalSize(); err == nil && dim.Width > 8 {
+				rp := *p
+				rp.Points = dim.Width - 8
+				p = &rp
+			}
+		}
+		result, warnings, err := p.RangeQuery(q)
+		if err != nil {
+			return bytes.Buffer{}, warnings, err
+		}
+		r := writer.RangeResult{Matrix: result}
+		// Exemplars are only drawn on graphs, so don't bother fetching them for other outputs
+		if p.Exemplars && p.Output == "" {
+			exemplars, err := p.ExemplarsQuery(q)
+			if err != nil {
+				return bytes.Buffer{}, warnings, err
+			}
+			r.Exemplars = exemplars
+		}
+		buf, err := writer.RenderRange(&r, p.Output, p.NoHeaders)
+		return buf, warnings, err
+	}
+	// Run query
+	result, warnings, err := p.InstantQuery(q)
+	if err != nil {
+		return bytes.Buffer{}, warnings, err
+	}
+	// Write out result
+	var r writer.InstantWriter
+	switch v := result.(type) {
+	case model.Matrix:
+		// Range vector selectors (e.g. up[5m]) return raw samples, which we write out like a range query
+		rr := writer.RangeResult{Matrix: v}
+		buf, err := writer.RenderRange(&rr, p.Output, p.NoHeaders)
+		return buf, warnings, err
+	case model.Vector:
+		r = &writer.InstantResult{Vector: v}
+	case *model.Scalar:
+		r = &writer.ScalarResult{Scalar: v}
+	case *model.String:
+		r = &writer.StringResult{String: v}
+	default:
+		return bytes.Buffer{}, warnings, fmt.Errorf("unsupported result type %s for instant query", result.Type())
+	}
+	buf, err := writer.RenderInstant(r, p.Output, p.NoHeaders)
+	return buf, warnings, err
+}
+
+// validateQuery checks the query locally and prints any problems found as warnings, see checkQuery
+func validateQuery(p *promql.PromQL, q string) error {
+	problems, err := checkQuery(p, q)
+	for _, problem := range problems {
+		errlog.Printf("Warning: %s\n", problem)
+	}
+	return err
+}
+
+// checkQuery parses the query locally, so syntax errors are caught before a round trip to the server and shown with a caret under them.
+// It also returns the problems found by the lint checks, e.g. rate() over a gauge or histogram_quantile() without le. Metric types are
+// fetched from the metadata API on a best effort basis, if they can't be fetched only the checks that don't need them are run.
+func checkQuery(p *promql.PromQL, q string) ([]string, error) {
+	if viper.GetBool("no-validate") {
+		return nil, nil
+	}
+	expanded, err := p.Expand(q)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := lint.Parse(expanded)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	// Errors are ignored, the checks that need types are skipped without them and the query itself will surface any problem with the server
+	meta, _ := queryMetadata(p, expr)
+	for _, problem := range lint.Check(expr, meta) {
+		problems = append(problems, lint.Caret(expanded, problem.Pos, problem.Message))
+	}
+	return problems, nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -135,21 +303,66 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&pql.CfgFile, "config", "", "config file location (default $HOME/.promql-cli.yaml)")
+	rootCmd.PersistentFlags().String("context", "", "named context from the config file to use for this invocation (default current-context)")
+	if err := viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context")); err != nil {
+		errlog.Fatalln(err)
+	}
+	addQueryFileFlag(rootCmd)
 	rootCmd.PersistentFlags().String("host", "http://0.0.0.0:9090", "prometheus server url")
 	if err := viper.BindPFlag("host", rootCmd.PersistentFlags().Lookup("host")); err != nil {
 		errlog.Fatalln(err)
 	}
-	rootCmd.PersistentFlags().String("step", "1m", "results step duration (h,m,s e.g. 1m)")
+	rootCmd.PersistentFlags().String("step", promql.AutoStep, "results step duration (e.g. 1m, 1h, 1d), or auto to derive it from the range and the number of points to return")
 	if err := viper.BindPFlag("step", rootCmd.PersistentFlags().Lookup("step")); err != nil {
 		errlog.Fatalln(err)
 	}
-	rootCmd.PersistentFlags().StringVar(&pql.Start, "start", "", "query range start duration (either as a lookback in h,m,s e.g. 1m, or as an ISO 8601 formatted date string). Required for range queries")
-	rootCmd.PersistentFlags().StringVar(&pql.End, "end", "now", "query range end (either 'now', or an ISO 8601 formatted date string)")
-	rootCmd.PersistentFlags().StringVar(&timeStr, "time", "now", "time for instant queries (either 'now', or an ISO 8601 formatted date string)")
+	rootCmd.PersistentFlags().Int("points", 0, fmt.Sprintf("number of points per series to aim for with an auto step (default the terminal width for graphs, %d otherwise)", promql.DefaultPoints))
+	if err := viper.BindPFlag("points", rootCmd.PersistentFlags().Lookup("points")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().String("split", "", "split range queries into chunks of this duration (e.g. 1d), or auto to split them to fit the server's limit of 11,000 points per series")
+	if err := viper.BindPFlag("split", rootCmd.PersistentFlags().Lookup("split")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().Int("split-concurrency", promql.DefaultSplitConcurrency, "number of chunks of a split range query to run at once")
+	if err := viper.BindPFlag("split-concurrency", rootCmd.PersistentFlags().Lookup("split-concurrency")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().Bool("cache", false, "cache query results on disk, so repeated range queries only fetch new samples and series and metadata results are reused")
+	if err := viper.BindPFlag("cache", rootCmd.PersistentFlags().Lookup("cache")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().String("cache-dir", "", "directory to store cached query results in (default $XDG_CACHE_HOME/promql-cli)")
+	if err := viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().String("cache-ttl", "5m", "how long cached series and metadata results are reused for")
+	if err := viper.BindPFlag("cache-ttl", rootCmd.PersistentFlags().Lookup("cache-ttl")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().StringVar(&pql.Start, "start", "", "query range start, either a lookback duration (e.g. 1h, 1d, 1w) or a time (e.g. now-2h, 1700000000, 2006-01-02T15:04:05Z, 2006-01-02, 15:04). Required for range queries")
+	rootCmd.PersistentFlags().StringVar(&pql.End, "end", "now", "query range end, in any of the formats accepted by --start")
+	rootCmd.PersistentFlags().StringArrayVar(&timeStrs, "time", []string{"now"}, "time for instant queries, in any of the formats accepted by --start. Repeat to compare the results at each time")
+	rootCmd.PersistentFlags().String("timezone", "Local", "timezone used to parse dates and times of day that don't include one (e.g. UTC or America/New_York)")
+	if err := viper.BindPFlag("timezone", rootCmd.PersistentFlags().Lookup("timezone")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.PersistentFlags().StringArrayVar(&varStrs, "var", nil, "set a query template variable, substituted for $name or ${name} in the query, can be repeated (e.g. --var cluster=prod)")
+	rootCmd.PersistentFlags().String("scrape-interval", promql.DefaultScrapeInterval.String(), "scrape interval of the server, used to derive the $__rate_interval template variable")
+	if err := viper.BindPFlag("scrape-interval", rootCmd.PersistentFlags().Lookup("scrape-interval")); err != nil {
+		errlog.Fatalln(err)
+	}
+	rootCmd.Flags().StringSliceVar(&compareOffsets, "compare", nil, "compare the result of an instant query to its result at offsets before --time (e.g. 1d,1w)")
+	rootCmd.Flags().BoolVar(&pql.Exemplars, "exemplars", false, "mark the exemplars of each series below its graph for range queries")
+	rootCmd.Flags().DurationVar(&watchInterval, "watch", 0, "re-run the query on the provided interval (h,m,s e.g. 5s) and redraw the result in place")
 	rootCmd.PersistentFlags().String("output", "", "override the default output format (graph for range queries, table for instant queries and metric names). Options: json,csv")
 	if err := viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")); err != nil {
 		errlog.Fatalln(err)
 	}
+	rootCmd.PersistentFlags().Bool("no-validate", false, "send queries to the server without parsing and checking them locally first, e.g. for syntax newer than promql-cli understands")
+	if err := viper.BindPFlag("no-validate", rootCmd.PersistentFlags().Lookup("no-validate")); err != nil {
+		errlog.Fatalln(err)
+	}
 	rootCmd.PersistentFlags().BoolVar(&pql.NoHeaders, "no-headers", false, "disable table headers for instant queries")
 	rootCmd.PersistentFlags().String("timeout", "10", "the timeout in seconds for all queries")
 	if err := viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout")); err != nil {
@@ -189,6 +402,27 @@ func init() {
 	}
 }
 
+// parseTime parses the value of a --time flag in the configured timezone, see util.ParseTime for the accepted formats
+func parseTime(s string) (time.Time, error) {
+	return util.ParseTime(s, time.Now(), pql.Location)
+}
+
+// templateVars merges the key=value variables of the --var flags over the variables set in the config file
+func templateVars(defaults map[string]string, flags []string) (map[string]string, error) {
+	vars := make(map[string]string, len(defaults)+len(flags))
+	for k, v := range defaults {
+		vars[k] = v
+	}
+	for _, kv := range flags {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid template variable %q, variables must be set as name=value", kv)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if pql.CfgFile != "" {
@@ -215,4 +449,9 @@ func initConfig() {
 			errlog.Printf("Could not read config file: %v\n", err)
 		}
 	}
+
+	// Overlay the settings of the selected context, if any
+	if err := applyContext(); err != nil {
+		errlog.Fatalln(err)
+	}
 }
@@ -0,0 +1,57 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// rules filter flags
+var (
+	rulesGroup string
+	rulesType  string
+)
+
+// rulesCmd represents the rules command
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Get a list of recording and alerting rules",
+	Long:  `Get a list of recording and alerting rules by rule group, including their evaluation health, last error and evaluation duration.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch rulesType {
+		case "", writer.AlertingRuleType, writer.RecordingRuleType:
+		default:
+			errlog.Fatalf("invalid rule type %q, must be one of alerting or recording\n", rulesType)
+		}
+		result, err := pql.RulesQuery()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		var r writer.RulesResult = result
+		r = r.Filter(rulesGroup, rulesType)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	rulesCmd.Flags().StringVar(&rulesGroup, "group", "", "only show rules in the provided rule group")
+	rulesCmd.Flags().StringVar(&rulesType, "type", "", "only show rules of the provided type. Options: alerting,recording")
+	rootCmd.AddCommand(rulesCmd)
+}
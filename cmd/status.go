@@ -0,0 +1,93 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// statusSections are the sections of the status report to print
+var statusSections []string
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Get the build info, runtime info, flags and config of the prometheus server",
+	Long: `Get a report of the prometheus server's version, uptime, storage retention, WAL corruptions, config reload status and the flags most likely to affect query results.
+
+The table and csv outputs summarize each section, while the json output contains the full flags and config file.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, s := range statusSections {
+			valid := false
+			for _, name := range writer.StatusSections {
+				if s == name {
+					valid = true
+				}
+			}
+			if !valid {
+				errlog.Fatalf("invalid section %s, must be one of %s\n", s, strings.Join(writer.StatusSections, ", "))
+			}
+		}
+		r := writer.StatusResult{Now: time.Now(), Sections: statusSections}
+		show := r.Include
+		// An endpoint that fails (e.g. on an older server) only drops its own section from the report
+		if show(writer.BuildSection) {
+			if b, err := pql.BuildinfoQuery(); err != nil {
+				errlog.Println(err)
+			} else {
+				r.Build = &b
+			}
+		}
+		// The config section includes the reload status from the runtime info
+		if show(writer.RuntimeSection) || show(writer.ConfigSection) {
+			if rt, err := pql.RuntimeinfoQuery(); err != nil {
+				errlog.Println(err)
+			} else {
+				r.Runtime = &rt
+			}
+		}
+		if show(writer.FlagsSection) {
+			if f, err := pql.FlagsQuery(); err != nil {
+				errlog.Println(err)
+			} else {
+				r.Flags = f
+			}
+		}
+		if show(writer.ConfigSection) {
+			if c, err := pql.ConfigQuery(); err != nil {
+				errlog.Println(err)
+			} else {
+				r.Config = &c
+			}
+		}
+		if r.Build == nil && r.Runtime == nil && r.Flags == nil && r.Config == nil {
+			errlog.Fatalf("unable to get the status of %s\n", pql.Host)
+		}
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringSliceVar(&statusSections, "section", nil, "sections of the report to print, one or more of build, runtime, flags, config (default all)")
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/nalbury/promql-cli/pkg/writer"
+	"github.com/spf13/cobra"
+)
+
+// targets filter flags
+var (
+	targetsJob    string
+	targetsHealth string
+	targetsPool   string
+)
+
+// targetsCmd represents the targets command
+var targetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Get the scrape health of prometheus targets",
+	Long:  `Get the scrape health of active prometheus targets, including the last scrape time, scrape duration, last error and target labels.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch targetsHealth {
+		case "", "up", "down", "unknown":
+		default:
+			errlog.Fatalf("invalid health %q, must be one of up, down or unknown\n", targetsHealth)
+		}
+		result, err := pql.TargetsQuery()
+		if err != nil {
+			errlog.Fatalln(err)
+		}
+		var r writer.TargetsResult = result
+		r = r.Filter(targetsJob, targetsHealth, targetsPool)
+		if err := writer.WriteInstant(&r, pql.Output, pql.NoHeaders); err != nil {
+			errlog.Fatalln(err)
+		}
+	},
+}
+
+func init() {
+	targetsCmd.Flags().StringVar(&targetsJob, "job", "", "only show targets with the provided job label")
+	targetsCmd.Flags().StringVar(&targetsHealth, "health", "", "only show targets with the provided health state. Options: up,down,unknown")
+	targetsCmd.Flags().StringVar(&targetsPool, "pool", "", "only show targets in the provided scrape pool")
+	rootCmd.AddCommand(targetsCmd)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nalbury/promql-cli/pkg/promql"
+)
+
+// ANSI escape sequences used to redraw the terminal in place
+const (
+	altScreenOn  = "\033[?1049h"
+	altScreenOff = "\033[?1049l"
+	cursorHide   = "\033[?25l"
+	cursorShow   = "\033[?25h"
+	cursorHome   = "\033[H"
+	clearLine    = "\033[K"
+	clearScreen  = "\033[J"
+)
+
+// runWatch re-runs the query every interval until interrupted, redrawing the result in place.
+// Relative times are re-evaluated on every run, so range queries with a relative start become a sliding window.
+func runWatch(p *promql.PromQL, q string, interval time.Duration) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Draw on the alternate screen so the user's scrollback is left untouched
+	fmt.Print(altScreenOn + cursorHide)
+	defer fmt.Print(cursorShow + altScreenOff)
+	for {
+		// Re-parse --time so relative times like now-1h move along with the clock, absolute times are unaffected
+		if t, err := parseTime(timeStrs[0]); err == nil {
+			p.Time = t
+		}
+		frame := watchFrame(p, q, interval)
+		// Overwrite the previous frame rather than clearing the screen first, which avoids flicker
+		fmt.Print(cursorHome + strings.ReplaceAll(frame, "\n", clearLine+"\n") + clearScreen)
+		select {
+		case <-sig:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchFrame runs the query and returns the full screen of output, including a header and any warnings or errors
+func watchFrame(p *promql.PromQL, q string, interval time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Every %s: %s\n", interval, strings.Join(strings.Fields(q), " "))
+	fmt.Fprintf(&b, "%s    %s\n\n", p.Host, time.Now().Format(time.RFC3339))
+	buf, warnings, err := renderQuery(p, q)
+	if len(warnings) > 0 {
+		fmt.Fprintf(&b, "Warnings: %v\n", warnings)
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "Error: %v\n", err)
+		return b.String()
+	}
+	b.WriteString(buf.String())
+	return b.String()
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// batch provides files of named queries that are run together by the batch command
+package batch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nalbury/promql-cli/pkg/util"
+)
+
+// Query is a single named query in a batch file. Settings left empty fall back to the command line flags.
+type Query struct {
+	Name      string `yaml:"name"`
+	Query     string `yaml:"query"`
+	Time      string `yaml:"time"`
+	Start     string `yaml:"start"`
+	End       string `yaml:"end"`
+	Step      string `yaml:"step"`
+	Output    string `yaml:"output"`
+	NoHeaders bool   `yaml:"no-headers"`
+	// Vars are the query template variables set for this query, on top of the --var flags
+	Vars map[string]string `yaml:"vars"`
+	// File is where the result is written, relative to the output directory if one is set
+	File string `yaml:"file"`
+}
+
+// Filename returns the name of the file the result is written to when writing results to a directory
+func (q Query) Filename() string {
+	if q.File != "" {
+		return q.File
+	}
+	ext := ".txt"
+	switch q.Output {
+	case "json":
+		ext = ".json"
+	case "csv":
+		ext = ".csv"
+	}
+	return q.Name + ext
+}
+
+// File is the parsed contents of a batch file
+type File struct {
+	Path string `yaml:"-"`
+	// Defaults are applied to every query that doesn't set its own
+	Defaults Query   `yaml:"defaults"`
+	Queries  []Query `yaml:"queries"`
+}
+
+// Load reads and parses the batch file at path, applying the defaults to each query
+func Load(path string) (File, error) {
+	f := File{Path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("error parsing batch file %s: %v", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return f, fmt.Errorf("error parsing batch file %s: %v", path, err)
+	}
+	for i := range f.Queries {
+		f.Queries[i] = withDefaults(f.Queries[i], f.Defaults)
+		// Queries can span multiple lines and include comment lines, just like query files
+		f.Queries[i].Query = util.CleanQuery(f.Queries[i].Query)
+	}
+	return f, nil
+}
+
+// validate checks every query has a query string and a unique name that's safe to use as a filename
+func (f File) validate() error {
+	if len(f.Queries) == 0 {
+		return fmt.Errorf("no queries found")
+	}
+	names := make(map[string]bool, len(f.Queries))
+	for i, q := range f.Queries {
+		if q.Name == "" {
+			return fmt.Errorf("query %d has no name", i+1)
+		}
+		if strings.ContainsAny(q.Name, `/\`) || q.Name == "." || q.Name == ".." {
+			return fmt.Errorf("query %s has an invalid name, names can't contain path separators", q.Name)
+		}
+		if names[q.Name] {
+			return fmt.Errorf("query %s is defined more than once", q.Name)
+		}
+		names[q.Name] = true
+		if util.CleanQuery(q.Query) == "" {
+			return fmt.Errorf("query %s has no query string", q.Name)
+		}
+		switch q.Output {
+		case "", "json", "csv":
+		default:
+			return fmt.Errorf("query %s has an unsupported output %s, options: json,csv", q.Name, q.Output)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills in the settings the query leaves empty from the defaults
+func withDefaults(q, d Query) Query {
+	if q.Time == "" {
+		q.Time = d.Time
+	}
+	if q.Start == "" {
+		q.Start = d.Start
+	}
+	if q.End == "" {
+		q.End = d.End
+	}
+	if q.Step == "" {
+		q.Step = d.Step
+	}
+	if q.Output == "" {
+		q.Output = d.Output
+	}
+	q.NoHeaders = q.NoHeaders || d.NoHeaders
+	vars := make(map[string]string, len(d.Vars)+len(q.Vars))
+	for k, v := range d.Vars {
+		vars[k] = v
+	}
+	for k, v := range q.Vars {
+		vars[k] = v
+	}
+	q.Vars = vars
+	return q
+}
+
+// ResultPath returns the path the result of q is written to within dir
+func ResultPath(dir string, q Query) string {
+	name := q.Filename()
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
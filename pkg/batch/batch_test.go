@@ -0,0 +1,78 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBatch = `defaults:
+  step: 1h
+  output: csv
+  vars:
+    cluster: prod
+queries:
+  - name: cpu
+    query: sum(rate(node_cpu_seconds_total[5m])) by (instance)
+  - name: memory
+    query: |
+      # available, not free, memory
+      sum(node_memory_MemAvailable_bytes)
+        by (instance)
+    start: 1w
+    step: 1d
+    output: json
+    file: reports/memory.json
+    vars:
+      cluster: staging
+`
+
+func writeTestBatch(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "queries.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	f, err := Load(writeTestBatch(t, testBatch))
+	assert.NoError(t, err)
+	assert.Len(t, f.Queries, 2)
+
+	cpu := f.Queries[0]
+	assert.Empty(t, cpu.Start)
+	assert.Equal(t, "1h", cpu.Step)
+	assert.Equal(t, "csv", cpu.Output)
+	assert.Equal(t, "cpu.csv", cpu.Filename())
+	assert.Equal(t, map[string]string{"cluster": "prod"}, cpu.Vars)
+
+	memory := f.Queries[1]
+	assert.Equal(t, "1w", memory.Start)
+	assert.Equal(t, "1d", memory.Step)
+	assert.Equal(t, "json", memory.Output)
+	assert.Equal(t, map[string]string{"cluster": "staging"}, memory.Vars)
+	assert.Equal(t, "sum(node_memory_MemAvailable_bytes)\n  by (instance)", memory.Query)
+	assert.Equal(t, filepath.Join("out", "reports", "memory.json"), ResultPath("out", memory))
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := map[string]string{
+		"empty":     "queries: []\n",
+		"no name":   "queries:\n  - query: up\n",
+		"no query":  "queries:\n  - name: up\n",
+		"comment":   "queries:\n  - name: up\n    query: '# up'\n",
+		"duplicate": "queries:\n  - name: up\n    query: up\n  - name: up\n    query: up\n",
+		"path":      "queries:\n  - name: ../up\n    query: up\n",
+		"output":    "queries:\n  - name: up\n    query: up\n    output: xml\n",
+		"yaml":      "queries: {\n",
+	}
+	for name, contents := range cases {
+		_, err := Load(writeTestBatch(t, contents))
+		assert.Error(t, err, name)
+	}
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
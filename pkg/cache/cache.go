@@ -0,0 +1,216 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cache provides an on-disk cache of query results that is safe to share between concurrent invocations
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileExt is the extension of cache entry files, only files with it are read, counted or cleared
+const fileExt = ".json"
+
+// Cache stores query results as one file per entry in Dir
+type Cache struct {
+	Dir string
+	// TTL is how long results that can't be partially refreshed, e.g. series and metadata, are reused for
+	TTL time.Duration
+}
+
+// entry is the contents of a cache file
+type entry struct {
+	Kind    string          `json:"kind"`
+	Key     string          `json:"key"`
+	Created time.Time       `json:"created"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Stat summarizes the cache entries of one kind
+type Stat struct {
+	Kind    string    `json:"kind"`
+	Entries int       `json:"entries"`
+	Bytes   int64     `json:"bytes"`
+	Expired int       `json:"expired"`
+	Oldest  time.Time `json:"oldest"`
+	Newest  time.Time `json:"newest"`
+}
+
+// DefaultDir returns the default cache directory, promql-cli in the user's cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "promql-cli"), nil
+}
+
+// New returns a cache stored in dir, creating the directory if it doesn't exist
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %v", err)
+	}
+	return &Cache{Dir: dir, TTL: ttl}, nil
+}
+
+// path returns the file of the entry for kind and key. Keys are hashed as they contain queries and hosts.
+func (c *Cache) path(kind string, key string) string {
+	sum := sha256.Sum256([]byte(kind + "\x00" + key))
+	return filepath.Join(c.Dir, kind+"-"+hex.EncodeToString(sum[:])+fileExt)
+}
+
+// Get decodes the entry for kind and key into v, returning when it was stored and whether it was found.
+// Unreadable or corrupt entries are treated as missing.
+func (c *Cache) Get(kind string, key string, v interface{}) (time.Time, bool) {
+	b, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var e entry
+	// Hash collisions are astronomically unlikely, but checking the key is cheap
+	if err := json.Unmarshal(b, &e); err != nil || e.Kind != kind || e.Key != key {
+		return time.Time{}, false
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return time.Time{}, false
+	}
+	return e.Created, true
+}
+
+// GetFresh is like Get, but entries older than the TTL are treated as missing
+func (c *Cache) GetFresh(kind string, key string, v interface{}) bool {
+	var raw json.RawMessage
+	created, ok := c.Get(kind, key, &raw)
+	if !ok || time.Since(created) > c.TTL {
+		return false
+	}
+	return json.Unmarshal(raw, v) == nil
+}
+
+// Put stores v as the entry for kind and key. The entry is written to a temporary file and renamed into place,
+// so concurrent readers only ever see a complete entry and the last concurrent writer wins.
+func (c *Cache) Put(kind string, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry{Kind: kind, Key: key, Created: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, ".tmp-"+kind+"-*")
+	if err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	if err := os.Rename(f.Name(), c.path(kind, key)); err != nil {
+		return fmt.Errorf("error writing cache entry: %v", err)
+	}
+	return nil
+}
+
+// files returns the entry files in the cache directory
+func (c *Cache) files() ([]os.DirEntry, error) {
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []os.DirEntry
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), fileExt) && !strings.HasPrefix(f.Name(), ".") {
+			entries = append(entries, f)
+		}
+	}
+	return entries, nil
+}
+
+// Stats returns a summary of the entries of each kind, sorted by kind.
+// Expired only counts entries older than the TTL, some kinds of entries are reused regardless of age.
+func (c *Cache) Stats() ([]Stat, error) {
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*Stat)
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			// Removed by a concurrent clear
+			continue
+		}
+		kind := strings.SplitN(f.Name(), "-", 2)[0]
+		s, ok := stats[kind]
+		if !ok {
+			s = &Stat{Kind: kind}
+			stats[kind] = s
+		}
+		s.Entries++
+		s.Bytes += info.Size()
+		modified := info.ModTime()
+		if time.Since(modified) > c.TTL {
+			s.Expired++
+		}
+		if s.Oldest.IsZero() || modified.Before(s.Oldest) {
+			s.Oldest = modified
+		}
+		if modified.After(s.Newest) {
+			s.Newest = modified
+		}
+	}
+	result := make([]Stat, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Kind < result[j].Kind })
+	return result, nil
+}
+
+// Clear removes every entry from the cache, returning the number removed
+func (c *Cache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range files {
+		if err := os.Remove(filepath.Join(c.Dir, f.Name())); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPut(t *testing.T) {
+	c, err := New(filepath.Join(t.TempDir(), "cache"), time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var v []string
+	_, ok := c.Get("series", "up", &v)
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Put("series", "up", []string{"a", "b"}))
+	created, ok := c.Get("series", "up", &v)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, v)
+	assert.WithinDuration(t, time.Now(), created, time.Minute)
+
+	// Kinds don't share entries
+	_, ok = c.Get("meta", "up", &v)
+	assert.False(t, ok)
+
+	// Corrupt entries are treated as missing
+	assert.NoError(t, os.WriteFile(c.path("series", "up"), []byte("{"), 0o600))
+	_, ok = c.Get("series", "up", &v)
+	assert.False(t, ok)
+}
+
+func TestGetFresh(t *testing.T) {
+	c, err := New(t.TempDir(), time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c.Put("meta", "up", "gauge"))
+	var v string
+	assert.True(t, c.GetFresh("meta", "up", &v))
+	assert.Equal(t, "gauge", v)
+	c.TTL = 0
+	assert.False(t, c.GetFresh("meta", "up", &v))
+}
+
+func TestStatsClear(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, c.Put("range", "a", 1))
+	assert.NoError(t, c.Put("range", "b", 2))
+	assert.NoError(t, c.Put("series", "a", 3))
+	// Files that aren't entries are left alone
+	assert.NoError(t, os.WriteFile(filepath.Join(c.Dir, "README"), []byte("hi"), 0o600))
+
+	stats, err := c.Stats()
+	assert.NoError(t, err)
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, "range", stats[0].Kind)
+		assert.Equal(t, 2, stats[0].Entries)
+		assert.Equal(t, "series", stats[1].Kind)
+		assert.Equal(t, 1, stats[1].Entries)
+		assert.Equal(t, 0, stats[1].Expired)
+	}
+
+	removed, err := c.Clear()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	stats, err = c.Stats()
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+	assert.FileExists(t, filepath.Join(c.Dir, "README"))
+}
+
+func TestConcurrentPut(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, c.Put("range", "up", fmt.Sprintf("result %d", i)))
+			var v string
+			// Readers only ever see a complete entry from one of the writers
+			if _, ok := c.Get("range", "up", &v); assert.True(t, ok) {
+				assert.Regexp(t, "^result [0-9]+$", v)
+			}
+		}(i)
+	}
+	wg.Wait()
+	files, err := os.ReadDir(c.Dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// config provides named server contexts stored in the promql-cli config file
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CurrentContextKey is the config file key holding the name of the default context
+	CurrentContextKey = "current-context"
+	// ContextsKey is the config file key holding the map of named contexts
+	ContextsKey = "contexts"
+)
+
+// ConnectionKeys are the settings that identify a server. When a context is selected these are
+// never inherited from the top level of the config file, so credentials don't leak between servers.
+var ConnectionKeys = []string{
+	"host",
+	"auth-type",
+	"auth-credentials",
+	"auth-credentials-file",
+	"tls_config",
+}
+
+// MergedKeys are the map settings a context adds to rather than replaces, e.g. query template variables
+// shared by every context with a context setting only the ones that differ between servers.
+var MergedKeys = []string{
+	"vars",
+}
+
+// File is the parsed contents of a promql-cli config file
+type File struct {
+	Path           string
+	CurrentContext string
+	Contexts       map[string]map[string]interface{}
+	Settings       map[string]interface{}
+}
+
+// Load reads and parses the config file at path. A missing file returns an empty File.
+func Load(path string) (File, error) {
+	f := File{
+		Path:     path,
+		Contexts: make(map[string]map[string]interface{}),
+		Settings: make(map[string]interface{}),
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return f, err
+	}
+	if err := yaml.Unmarshal(b, &f.Settings); err != nil {
+		return f, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	if f.Settings == nil {
+		f.Settings = make(map[string]interface{})
+	}
+	if c, ok := f.Settings[CurrentContextKey].(string); ok {
+		f.CurrentContext = c
+	}
+	if contexts, ok := f.Settings[ContextsKey].(map[string]interface{}); ok {
+		for name, c := range contexts {
+			settings, ok := c.(map[string]interface{})
+			if !ok && c != nil {
+				return f, fmt.Errorf("error parsing config file %s: context %s is not a map", path, name)
+			}
+			if settings == nil {
+				settings = make(map[string]interface{})
+			}
+			f.Contexts[name] = settings
+		}
+	}
+	return f, nil
+}
+
+// Names returns the sorted names of all contexts in the file
+func (f File) Names() []string {
+	names := make([]string, 0, len(f.Contexts))
+	for n := range f.Contexts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the top level settings of the file overlaid with the settings of the named context.
+// Connection settings are taken exclusively from the context, and the MergedKeys maps of both are merged.
+func (f File) Resolve(name string) (map[string]interface{}, error) {
+	c, ok := f.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("context %s not found in %s", name, f.Path)
+	}
+	settings := make(map[string]interface{}, len(f.Settings)+len(c))
+	for k, v := range f.Settings {
+		settings[k] = v
+	}
+	for _, k := range ConnectionKeys {
+		delete(settings, k)
+	}
+	for k, v := range c {
+		settings[k] = v
+	}
+	for _, k := range MergedKeys {
+		top, ok := f.Settings[k].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		merged := make(map[string]interface{}, len(top))
+		for mk, mv := range top {
+			merged[mk] = mv
+		}
+		if ctx, ok := c[k].(map[string]interface{}); ok {
+			for mk, mv := range ctx {
+				merged[mk] = mv
+			}
+		}
+		settings[k] = merged
+	}
+	return settings, nil
+}
+
+// SetCurrentContext updates the current context of the config file at path, preserving the rest of the file
+func SetCurrentContext(path string, name string) error {
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Contexts[name]; !ok {
+		return fmt.Errorf("context %s not found in %s", name, path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("error parsing config file %s: expected a map", path)
+	}
+	root := doc.Content[0]
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+	set := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == CurrentContextKey {
+			root.Content[i+1] = value
+			set = true
+		}
+	}
+	if !set {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: CurrentContextKey}
+		root.Content = append([]*yaml.Node{key, value}, root.Content...)
+	}
+	o, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, o, info.Mode())
+}
+
+// Flatten converts nested settings into a map of dot separated keys, e.g. tls_config.ca_cert_file
+func Flatten(settings map[string]interface{}) map[string]string {
+	flat := make(map[string]string)
+	flatten("", settings, flat)
+	return flat
+}
+
+func flatten(prefix string, settings map[string]interface{}, flat map[string]string) {
+	for k, v := range settings {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			flatten(key, m, flat)
+			continue
+		}
+		flat[key] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `# promql-cli config
+output: csv
+auth-type: Basic
+auth-credentials: secret
+vars:
+  cluster: default
+  namespace: monitoring
+contexts:
+  prod:
+    host: https://prod.example.com
+    tls_config:
+      insecure_skip_verify: true
+  staging:
+    host: https://staging.example.com
+    step: 5m
+    output: json
+    vars:
+      cluster: staging
+`
+
+func writeTestConfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	f, err := Load(writeTestConfig(t))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "staging"}, f.Names())
+	assert.Equal(t, "", f.CurrentContext)
+
+	missing, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NoError(t, err)
+	assert.Empty(t, missing.Names())
+}
+
+func TestResolve(t *testing.T) {
+	f, err := Load(writeTestConfig(t))
+	assert.NoError(t, err)
+
+	prod, err := f.Resolve("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://prod.example.com", prod["host"])
+	assert.Equal(t, "csv", prod["output"])
+	// Connection settings must not be inherited from the top level
+	assert.NotContains(t, prod, "auth-type")
+	assert.NotContains(t, prod, "auth-credentials")
+
+	staging, err := f.Resolve("staging")
+	assert.NoError(t, err)
+	assert.Equal(t, "json", staging["output"])
+	assert.Equal(t, "5m", staging["step"])
+	// Variables are merged rather than replaced
+	assert.Equal(t, map[string]interface{}{"cluster": "staging", "namespace": "monitoring"}, staging["vars"])
+	assert.Equal(t, map[string]interface{}{"cluster": "default", "namespace": "monitoring"}, prod["vars"])
+
+	_, err = f.Resolve("dev")
+	assert.Error(t, err)
+}
+
+func TestSetCurrentContext(t *testing.T) {
+	path := writeTestConfig(t)
+	assert.NoError(t, SetCurrentContext(path, "staging"))
+	f, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", f.CurrentContext)
+
+	assert.NoError(t, SetCurrentContext(path, "prod"))
+	f, err = Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", f.CurrentContext)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "# promql-cli config")
+
+	assert.Error(t, SetCurrentContext(path, "dev"))
+}
+
+func TestFlatten(t *testing.T) {
+	f, err := Load(writeTestConfig(t))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"host":                            "https://prod.example.com",
+		"tls_config.insecure_skip_verify": "true",
+	}, Flatten(f.Contexts["prod"]))
+}
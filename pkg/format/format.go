@@ -0,0 +1,156 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// format provides pretty-printing and syntax highlighting of promql queries using the upstream promql parser
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/nalbury/promql-cli/pkg/lint"
+)
+
+// Format parses the query and returns it pretty-printed, with nested aggregations and
+// binary operators that don't fit on one line split over indented lines. Invalid queries return a *lint.SyntaxError.
+func Format(query string) (string, error) {
+	expr, err := lint.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	return parser.Prettify(expr), nil
+}
+
+// FormatFile formats the query in the contents of a query file. The comment lines at the top of the file are kept,
+// but comments within the query would be dropped by the parser, so files with them are returned as an error.
+func FormatFile(contents string) (string, error) {
+	lines := strings.Split(strings.TrimRight(contents, "\n"), "\n")
+	header := 0
+	for header < len(lines) {
+		trimmed := strings.TrimSpace(lines[header])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		header++
+	}
+	body := strings.Join(lines[header:], "\n")
+	// Prettify drops comments, so refuse to format rather than lose them, including ones at the end of a line
+	if pos, ok := commentPos(body); ok {
+		line := header + strings.Count(body[:pos], "\n") + 1
+		return "", fmt.Errorf("line %d: comments within a query can't be formatted, move them to the top of the file", line)
+	}
+	formatted, err := Format(body)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, line := range lines[:header] {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(formatted + "\n")
+	return b.String(), nil
+}
+
+// commentPos returns the position of the first comment in a query, if it has one
+func commentPos(query string) (int, bool) {
+	var item parser.Item
+	l := parser.Lex(query)
+	for {
+		l.NextItem(&item)
+		switch item.Typ {
+		case parser.EOF, parser.ERROR:
+			return 0, false
+		case parser.COMMENT:
+			return int(item.Pos), true
+		}
+	}
+}
+
+// ANSI escape sequences used to highlight queries
+const (
+	colorReset    = "\033[0m"
+	colorKeyword  = "\033[35m"
+	colorFunction = "\033[34m"
+	colorLabel    = "\033[36m"
+	colorString   = "\033[32m"
+	colorNumber   = "\033[33m"
+	colorComment  = "\033[90m"
+)
+
+// Highlight returns the query with ANSI colors for aggregations and keywords, functions, label names, strings,
+// numbers and durations, and comments. Whitespace is left as is, so it can be used on formatted queries.
+func Highlight(query string) string {
+	var (
+		b    strings.Builder
+		item parser.Item
+		last int
+		// depth is how many braces deep the current item is, items within braces are label names, operators or values
+		depth int
+		// grouping is set after by, without, on, ignoring and group_left/right until the end of their list of labels
+		grouping, inGrouping bool
+	)
+	l := parser.Lex(query)
+	for {
+		l.NextItem(&item)
+		if item.Typ == parser.EOF || item.Typ == parser.ERROR {
+			break
+		}
+		start := int(item.Pos)
+		end := start + len(item.Val)
+		b.WriteString(query[last:start])
+		last = end
+		color := ""
+		switch item.Typ {
+		case parser.BY, parser.WITHOUT, parser.ON, parser.IGNORING, parser.GROUP_LEFT, parser.GROUP_RIGHT:
+			grouping = true
+		case parser.LEFT_PAREN:
+			inGrouping = grouping
+			grouping = false
+		case parser.RIGHT_PAREN:
+			inGrouping = false
+		default:
+			grouping = false
+		}
+		switch {
+		case item.Typ == parser.LEFT_BRACE:
+			depth++
+		case item.Typ == parser.RIGHT_BRACE:
+			depth--
+		case item.Typ.IsAggregator() || item.Typ.IsKeyword() || item.Typ.IsSetOperator() || item.Typ == parser.ATAN2:
+			color = colorKeyword
+		case item.Typ == parser.IDENTIFIER && (depth > 0 || inGrouping):
+			color = colorLabel
+		case item.Typ == parser.IDENTIFIER && parser.Functions[item.Val] != nil:
+			color = colorFunction
+		case item.Typ == parser.STRING:
+			color = colorString
+		case item.Typ == parser.NUMBER || item.Typ == parser.DURATION:
+			color = colorNumber
+		case item.Typ == parser.COMMENT:
+			color = colorComment
+		}
+		if color == "" {
+			b.WriteString(item.Val)
+			continue
+		}
+		b.WriteString(color + item.Val + colorReset)
+	}
+	// Anything the lexer couldn't make sense of is written out as is
+	b.WriteString(query[last:])
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		Query    string
+		Expected string
+		Error    bool
+	}{
+		{Query: "up", Expected: "up"},
+		{Query: "sum   by(job)(up{job = 'api'})", Expected: `sum by (job) (up{job="api"})`},
+		{
+			Query: `histogram_quantile(0.99, sum by (le, job) (rate(http_request_duration_seconds_bucket{job="api",code=~"5.."}[5m]))) > on(job) group_left(team) max by (job, team) (team_info)`,
+			Expected: `  histogram_quantile(
+    0.99,
+    sum by (le, job) (rate(http_request_duration_seconds_bucket{code=~"5..",job="api"}[5m]))
+  )
+> on (job) group_left (team)
+  max by (job, team) (team_info)`,
+		},
+		{Query: "sum(rate(up[5m])", Error: true},
+	}
+	for _, c := range cases {
+		formatted, err := Format(c.Query)
+		if c.Error {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, c.Expected, formatted)
+	}
+}
+
+func TestFormatFile(t *testing.T) {
+	formatted, err := FormatFile("# Per-job request rate\n\nsum(rate(http_requests_total[5m]))\n  by (job)\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "# Per-job request rate\n\nsum by (job) (rate(http_requests_total[5m]))\n", formatted)
+
+	_, err = FormatFile("sum(\n  # only 5xx responses\n  rate(http_requests_total{code=~\"5..\"}[5m])\n)\n")
+	assert.EqualError(t, err, "line 2: comments within a query can't be formatted, move them to the top of the file")
+
+	_, err = FormatFile("# Per-job request rate\nsum(rate(http_requests_total[5m]))\n  by (job) # not instance\n")
+	assert.EqualError(t, err, "line 3: comments within a query can't be formatted, move them to the top of the file")
+
+	// A # within a string isn't a comment
+	formatted, err = FormatFile(`up{job="#1"}` + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, `up{job="#1"}`+"\n", formatted)
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t,
+		colorKeyword+"sum"+colorReset+" "+colorKeyword+"by"+colorReset+" ("+colorLabel+"job"+colorReset+") ("+
+			colorFunction+"rate"+colorReset+"(up{"+colorLabel+"job"+colorReset+"="+colorString+`"api"`+colorReset+"}["+colorNumber+"5m"+colorReset+"]))",
+		Highlight(`sum by (job) (rate(up{job="api"}[5m]))`),
+	)
+	// Anything that can't be lexed is left as is
+	assert.Equal(t, colorFunction+"rate"+colorReset+"(up $ [5m]", Highlight("rate(up $ [5m]"))
+}
@@ -0,0 +1,273 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// lint provides local syntax and semantic checks of promql queries using the upstream promql parser
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// SyntaxError is a query that failed to parse, along with the position of the error within it
+type SyntaxError struct {
+	Query string
+	// Pos is the byte offset of the error within the query
+	Pos int
+	Msg string
+}
+
+// Error returns the error message followed by the offending line of the query with a caret under the error
+func (e *SyntaxError) Error() string {
+	return Caret(e.Query, e.Pos, "syntax error: "+e.Msg)
+}
+
+// Parse parses the query, returning a *SyntaxError if it isn't valid promql
+func Parse(query string) (parser.Expr, error) {
+	expr, err := parser.ParseExpr(query)
+	if err == nil {
+		return expr, nil
+	}
+	var errs parser.ParseErrors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		return nil, &SyntaxError{Query: query, Pos: int(errs[0].PositionRange.Start), Msg: errs[0].Err.Error()}
+	}
+	return nil, err
+}
+
+// Caret formats msg prefixed with the line and column of pos, followed by the line of the query containing pos and a caret under its column
+func Caret(query string, pos int, msg string) string {
+	if pos < 0 || pos > len(query) {
+		return msg
+	}
+	lineStart := strings.LastIndex(query[:pos], "\n") + 1
+	lineEnd := strings.Index(query[pos:], "\n")
+	if lineEnd < 0 {
+		lineEnd = len(query)
+	} else {
+		lineEnd += pos
+	}
+	line := strings.Count(query[:pos], "\n") + 1
+	col := utf8.RuneCountInString(query[lineStart:pos]) + 1
+	// Keep any tabs before the caret, so it lines up regardless of the terminal's tab width
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, query[lineStart:pos])
+	return fmt.Sprintf("%d:%d: %s\n%s\n%s^", line, col, msg, query[lineStart:lineEnd], indent)
+}
+
+// Problem is a semantic issue found in a query
+type Problem struct {
+	// Pos is the byte offset of the problem within the query
+	Pos     int
+	Message string
+}
+
+// counterFuncs are the functions that only make sense over counters
+var counterFuncs = map[string]bool{
+	"rate":     true,
+	"irate":    true,
+	"increase": true,
+	"resets":   true,
+}
+
+// gaugeFuncs are the functions that only make sense over gauges, with the function to use over counters instead
+var gaugeFuncs = map[string]string{
+	"delta":          "increase",
+	"idelta":         "irate",
+	"deriv":          "rate",
+	"predict_linear": "",
+	"holt_winters":   "",
+}
+
+// Check runs semantic checks over a parsed query. Checks of the types of metrics use the provided metadata,
+// and are skipped for metrics without any, so a nil map only runs the checks that don't need types.
+func Check(expr parser.Expr, meta map[string][]v1.Metadata) []Problem {
+	var problems []Problem
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		call, ok := node.(*parser.Call)
+		if !ok {
+			return nil
+		}
+		switch {
+		case counterFuncs[call.Func.Name]:
+			for _, name := range rangeMetrics(call) {
+				if t := metricType(meta, name); t != "" && t != v1.MetricTypeCounter && t != v1.MetricTypeUnknown {
+					problems = append(problems, Problem{
+						Pos:     int(call.PositionRange().Start),
+						Message: fmt.Sprintf("%s() should only be used with counters, but %s is a %s", call.Func.Name, name, t),
+					})
+				}
+			}
+		case call.Func.Name == "histogram_quantile" && len(call.Args) == 2:
+			problems = append(problems, checkHistogramQuantile(call, meta)...)
+		default:
+			instead, ok := gaugeFuncs[call.Func.Name]
+			if !ok {
+				return nil
+			}
+			for _, name := range rangeMetrics(call) {
+				if metricType(meta, name) != v1.MetricTypeCounter {
+					continue
+				}
+				msg := fmt.Sprintf("%s() should only be used with gauges, but %s is a counter", call.Func.Name, name)
+				if instead != "" {
+					msg += fmt.Sprintf(", use %s() instead", instead)
+				}
+				problems = append(problems, Problem{Pos: int(call.PositionRange().Start), Message: msg})
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Pos < problems[j].Pos
+	})
+	return problems
+}
+
+// checkHistogramQuantile checks the buckets passed to histogram_quantile still have their le label
+func checkHistogramQuantile(call *parser.Call, meta map[string][]v1.Metadata) []Problem {
+	var problems []Problem
+	var check func(node parser.Node)
+	check = func(node parser.Node) {
+		switch n := node.(type) {
+		case *parser.AggregateExpr:
+			if !keepsLabel(n, "le") {
+				problems = append(problems, Problem{
+					Pos:     int(n.PositionRange().Start),
+					Message: fmt.Sprintf("histogram_quantile() needs the le label of the buckets, but %s aggregates it away, add le to its by clause", n.Op),
+				})
+				// Anything below this aggregation doesn't matter, le is already gone
+				return
+			}
+		case *parser.VectorSelector:
+			// Native histograms don't have buckets, so only metrics with a known type other than histogram are wrong
+			t := metricType(meta, n.Name)
+			if n.Name != "" && !strings.Contains(n.Name, "_bucket") && t != "" && t != v1.MetricTypeHistogram && t != v1.MetricTypeUnknown {
+				problems = append(problems, Problem{
+					Pos:     int(n.PositionRange().Start),
+					Message: fmt.Sprintf("histogram_quantile() needs the _bucket series of a histogram, but %s is a %s", n.Name, t),
+				})
+			}
+		}
+		for _, child := range parser.Children(node) {
+			check(child)
+		}
+	}
+	check(call.Args[1])
+	return problems
+}
+
+// keepsLabel returns true if the labels of the result of an aggregation include label
+func keepsLabel(agg *parser.AggregateExpr, label string) bool {
+	switch agg.Op {
+	case parser.TOPK, parser.BOTTOMK:
+		// topk and bottomk return the original series
+		return true
+	}
+	for _, l := range agg.Grouping {
+		if l == label {
+			return !agg.Without
+		}
+	}
+	return agg.Without
+}
+
+// rangeMetrics returns the metric names of the range vector selectors passed to a function
+func rangeMetrics(call *parser.Call) []string {
+	var names []string
+	for _, arg := range call.Args {
+		if m, ok := arg.(*parser.MatrixSelector); ok {
+			if vs, ok := m.VectorSelector.(*parser.VectorSelector); ok && vs.Name != "" {
+				names = append(names, vs.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Metrics returns the sorted, unique metric names of the vector selectors in a parsed query
+func Metrics(expr parser.Expr) []string {
+	seen := make(map[string]bool)
+	var names []string
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		if vs, ok := node.(*parser.VectorSelector); ok && vs.Name != "" && !seen[vs.Name] {
+			seen[vs.Name] = true
+			names = append(names, vs.Name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+// MetadataNames returns the sorted, unique names to fetch metadata for to check a parsed query. Metadata is reported per metric family,
+// so along with the metric names of the query, it includes the family names of series like _bucket, _count and _total.
+func MetadataNames(expr parser.Expr) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range Metrics(expr) {
+		add(name)
+		for _, suffix := range typeSuffixes {
+			if base := strings.TrimSuffix(name, suffix); base != name {
+				add(base)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeSuffixes are the suffixes of the series of a metric family
+var typeSuffixes = []string{"_bucket", "_sum", "_count", "_total", "_created"}
+
+// metricType returns the type of a metric from its metadata, or an empty type if it isn't known.
+// The _bucket, _sum and _count series of histograms and summaries are counters, and those of gauge histograms are gauges.
+func metricType(meta map[string][]v1.Metadata, name string) v1.MetricType {
+	if m, ok := meta[name]; ok && len(m) > 0 {
+		return m[0].Type
+	}
+	for _, suffix := range typeSuffixes {
+		base := strings.TrimSuffix(name, suffix)
+		if base == name {
+			continue
+		}
+		if m, ok := meta[base]; ok && len(m) > 0 {
+			switch m[0].Type {
+			case v1.MetricTypeHistogram, v1.MetricTypeSummary, v1.MetricTypeCounter:
+				return v1.MetricTypeCounter
+			case v1.MetricTypeGaugeHistogram:
+				return v1.MetricTypeGauge
+			}
+		}
+	}
+	return ""
+}
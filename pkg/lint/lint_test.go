@@ -0,0 +1,107 @@
+package lint
+
+import (
+	"testing"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	_, err := Parse(`sum(rate(http_requests_total[5m])) by (job)`)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		Query    string
+		Expected string
+	}{
+		{
+			Query:    "sum(rate(up[5m])",
+			Expected: "1:17: syntax error: unclosed left parenthesis\nsum(rate(up[5m])\n                ^",
+		},
+		{
+			Query:    "sum(\n\trate(up{job=\"api\"[5m])\n)",
+			Expected: "2:19: syntax error: unexpected character inside braces: '['\n\trate(up{job=\"api\"[5m])\n\t                 ^",
+		},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.Query)
+		assert.IsType(t, &SyntaxError{}, err)
+		assert.EqualError(t, err, c.Expected)
+	}
+}
+
+func TestCaret(t *testing.T) {
+	assert.Equal(t, "1:5: msg\nrate(x)\n    ^", Caret("rate(x)", 4, "msg"))
+	assert.Equal(t, "1:8: msg\nrate(x)\n       ^", Caret("rate(x)", 7, "msg"))
+	assert.Equal(t, "2:3: msg\n  x\n  ^", Caret("y\n  x", 4, "msg"))
+	assert.Equal(t, "msg", Caret("x", 5, "msg"))
+}
+
+func TestCheck(t *testing.T) {
+	meta := map[string][]v1.Metadata{
+		"http_requests_total":           {{Type: v1.MetricTypeCounter}},
+		"http_request_duration_seconds": {{Type: v1.MetricTypeHistogram}},
+		"node_memory_MemFree_bytes":     {{Type: v1.MetricTypeGauge}},
+		"rpc_duration_seconds":          {{Type: v1.MetricTypeSummary}},
+	}
+	cases := []struct {
+		Query    string
+		Meta     map[string][]v1.Metadata
+		Expected []Problem
+	}{
+		{Query: `sum(rate(http_requests_total[5m])) by (job)`, Meta: meta},
+		{Query: `rate(http_request_duration_seconds_count[5m])`, Meta: meta},
+		{Query: `rate(unknown_metric[5m])`, Meta: meta},
+		{
+			Query:    `sum(rate(node_memory_MemFree_bytes[5m]))`,
+			Meta:     meta,
+			Expected: []Problem{{Pos: 4, Message: "rate() should only be used with counters, but node_memory_MemFree_bytes is a gauge"}},
+		},
+		{
+			Query:    `delta(http_requests_total[1h])`,
+			Meta:     meta,
+			Expected: []Problem{{Pos: 0, Message: "delta() should only be used with gauges, but http_requests_total is a counter, use increase() instead"}},
+		},
+		{Query: `histogram_quantile(0.99, sum by (le) (rate(http_request_duration_seconds_bucket[5m])))`, Meta: meta},
+		{Query: `histogram_quantile(0.99, sum without (job) (rate(http_request_duration_seconds_bucket[5m])))`, Meta: meta},
+		{
+			Query:    `histogram_quantile(0.99, sum by (job) (rate(http_request_duration_seconds_bucket[5m])))`,
+			Expected: []Problem{{Pos: 25, Message: "histogram_quantile() needs the le label of the buckets, but sum aggregates it away, add le to its by clause"}},
+		},
+		{
+			Query:    `histogram_quantile(0.99, sum without (le) (rate(http_request_duration_seconds_bucket[5m])))`,
+			Expected: []Problem{{Pos: 25, Message: "histogram_quantile() needs the le label of the buckets, but sum aggregates it away, add le to its by clause"}},
+		},
+		{
+			Query:    `histogram_quantile(0.5, rpc_duration_seconds)`,
+			Meta:     meta,
+			Expected: []Problem{{Pos: 24, Message: "histogram_quantile() needs the _bucket series of a histogram, but rpc_duration_seconds is a summary"}},
+		},
+		// Series of a family are typed by the metadata of the family
+		{
+			Query:    `delta(rpc_duration_seconds_count[5m])`,
+			Meta:     map[string][]v1.Metadata{"rpc_duration_seconds": {{Type: v1.MetricTypeHistogram}}},
+			Expected: []Problem{{Pos: 0, Message: "delta() should only be used with gauges, but rpc_duration_seconds_count is a counter, use increase() instead"}},
+		},
+		// Without metadata, only the checks that don't need types are run
+		{Query: `rate(node_memory_MemFree_bytes[5m])`},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.Query)
+		assert.NoError(t, err)
+		assert.Equal(t, c.Expected, Check(expr, c.Meta), c.Query)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	expr, err := Parse(`sum(rate(http_requests_total{code="500"}[5m])) / sum(rate(http_requests_total[5m])) > on() vector(1) * up`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http_requests_total", "up"}, Metrics(expr))
+}
+
+func TestMetadataNames(t *testing.T) {
+	expr, err := Parse(`delta(rpc_duration_seconds_count[5m]) / rate(http_requests_total[5m]) + up`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http_requests", "http_requests_total", "rpc_duration_seconds", "rpc_duration_seconds_count", "up"}, MetadataNames(expr))
+}
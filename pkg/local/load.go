@@ -0,0 +1,204 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// local provides a Prometheus API backed by series loaded from local files, so queries can be evaluated without a server
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// loadCmd starts a block of series notation, followed by the interval between its samples e.g. load 1m
+const loadCmd = "load"
+
+// openMetricsEOF is the line that ends an OpenMetrics exposition
+const openMetricsEOF = "# EOF"
+
+// Sample is a single value of a series, at a timestamp in milliseconds
+type Sample struct {
+	T int64
+	V float64
+}
+
+// Series is a series loaded from a data file, with its samples in timestamp order
+type Series struct {
+	Labels  labels.Labels
+	Samples []Sample
+}
+
+// LoadFile reads the series of a data file, see Parse for the supported formats
+func LoadFile(path string, anchor time.Time) ([]Series, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read data file, %v", err)
+	}
+	series, err := Parse(b, anchor)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s, %v", path, err)
+	}
+	return series, nil
+}
+
+// Parse reads series in either the promtool series notation used by rule unit tests, or the Prometheus text exposition or OpenMetrics formats.
+//
+// Series notation is placed so its longest series ends at the anchor time, keeping series aligned to each other as promtool would,
+// and exposition samples without a timestamp are placed at the anchor time.
+func Parse(b []byte, anchor time.Time) ([]Series, error) {
+	if isSeriesNotation(b) {
+		return parseSeriesNotation(b, anchor)
+	}
+	return parseExposition(b, anchor)
+}
+
+// isSeriesNotation reports if the first line that isn't blank or a comment is a load command
+func isSeriesNotation(b []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, _, _ := strings.Cut(line, " ")
+		return cmd == loadCmd
+	}
+	return false
+}
+
+// parseSeriesNotation reads load blocks of series notation, e.g.
+//
+//	load 1m
+//	  http_requests_total{job="api"} 0+10x60
+func parseSeriesNotation(b []byte, anchor time.Time) ([]Series, error) {
+	var (
+		series   []Series
+		interval time.Duration
+		// end is the offset of the latest sample from the start of the data
+		end int64
+	)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if cmd, arg, _ := strings.Cut(line, " "); cmd == loadCmd {
+			d, err := model.ParseDuration(strings.TrimSpace(arg))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("line %d: invalid load interval %q", n, strings.TrimSpace(arg))
+			}
+			interval = time.Duration(d)
+			continue
+		}
+		lset, values, err := parser.ParseSeriesDesc(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		l := Series{Labels: lset}
+		for i, v := range values {
+			if v.Omitted {
+				continue
+			}
+			t := int64(i) * interval.Milliseconds()
+			l.Samples = append(l.Samples, Sample{T: t, V: v.Value})
+			if t > end {
+				end = t
+			}
+		}
+		series = append(series, l)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	// Shift the samples so the latest lands on the anchor
+	start := anchor.UnixMilli() - end
+	for _, l := range series {
+		for i := range l.Samples {
+			l.Samples[i].T += start
+		}
+	}
+	return series, nil
+}
+
+// parseExposition reads metrics in the text exposition format, or OpenMetrics if the exposition ends with # EOF
+func parseExposition(b []byte, anchor time.Time) ([]Series, error) {
+	contentType := "text/plain"
+	if bytes.Equal(bytes.TrimSpace(lastLine(b)), []byte(openMetricsEOF)) {
+		contentType = "application/openmetrics-text"
+	}
+	p, err := textparse.New(b, contentType)
+	if err != nil {
+		return nil, err
+	}
+	var series []Series
+	// index tracks the position of each series in the results, so repeated samples of a series are grouped together
+	index := map[string]int{}
+	for {
+		entry, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry != textparse.EntrySeries {
+			continue
+		}
+		_, ts, v := p.Series()
+		t := anchor.UnixMilli()
+		if ts != nil {
+			t = *ts
+		}
+		var lset labels.Labels
+		p.Metric(&lset)
+		key := lset.String()
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, Series{Labels: lset})
+		}
+		series[i].Samples = append(series[i].Samples, Sample{T: t, V: v})
+	}
+	// Samples have to be appended in order, but expositions captured over time may have been concatenated in any order
+	for _, l := range series {
+		sort.SliceStable(l.Samples, func(i, j int) bool {
+			return l.Samples[i].T < l.Samples[j].T
+		})
+	}
+	return series, nil
+}
+
+// lastLine returns the last line of b that isn't blank
+func lastLine(b []byte) []byte {
+	b = bytes.TrimRight(b, " \t\r\n")
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		return b[i+1:]
+	}
+	return b
+}
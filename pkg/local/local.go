@@ -0,0 +1,270 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/tsdb"
+)
+
+const (
+	// maxSamples is the most samples a query can load into memory, matching the Prometheus default
+	maxSamples = 50000000
+	// subqueryStep is the step of subqueries that don't set one, Prometheus uses its global evaluation interval which defaults to 1m
+	subqueryStep = time.Minute
+)
+
+// Storage is a temporary TSDB holding the series loaded from data files
+type Storage struct {
+	dir    string
+	db     *tsdb.DB
+	engine *promql.Engine
+}
+
+// Open loads the series of the data files into a new storage, see Parse for the formats supported and how the anchor time is used
+func Open(paths []string, anchor time.Time, timeout time.Duration) (*Storage, error) {
+	var series []Series
+	for _, path := range paths {
+		s, err := LoadFile(path, anchor)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, s...)
+	}
+	dir, err := os.MkdirTemp("", "promql-cli-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create storage directory, %v", err)
+	}
+	opts := tsdb.DefaultOptions()
+	// The storage only lives as long as the command, so there's nothing to recover on restart
+	opts.WALSegmentSize = -1
+	db, err := tsdb.Open(dir, nil, nil, opts, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("unable to open storage, %v", err)
+	}
+	db.DisableCompactions()
+	s := &Storage{
+		dir: dir,
+		db:  db,
+		engine: promql.NewEngine(promql.EngineOpts{
+			MaxSamples:           maxSamples,
+			Timeout:              timeout,
+			EnableAtModifier:     true,
+			EnableNegativeOffset: true,
+			NoStepSubqueryIntervalFn: func(int64) int64 {
+				return subqueryStep.Milliseconds()
+			},
+		}),
+	}
+	if err := s.append(series); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// append writes the series in a single transaction, the head only accepts samples older than its newest ones within a transaction
+func (s *Storage) append(series []Series) error {
+	app := s.db.Appender(context.Background())
+	for _, l := range series {
+		for _, sample := range l.Samples {
+			if _, err := app.Append(0, l.Labels, sample.T, sample.V); err != nil {
+				app.Rollback()
+				return fmt.Errorf("unable to load sample of %s at %s, %v", l.Labels, model.Time(sample.T).Time().UTC().Format(time.RFC3339), err)
+			}
+		}
+	}
+	return app.Commit()
+}
+
+// Close closes the storage and removes its directory
+func (s *Storage) Close() error {
+	defer os.RemoveAll(s.dir)
+	return s.db.Close()
+}
+
+// errNotSupported is returned by the endpoints of the API other than instant and range queries
+var errNotSupported = errors.New("not supported by eval")
+
+// API returns a Prometheus API that evaluates queries against the storage.
+// Only instant and range queries are supported, the other endpoints return an error.
+func (s *Storage) API() v1.API {
+	return &api{s: s}
+}
+
+// api serves queries with the promql engine instead of a server
+type api struct {
+	s *Storage
+}
+
+// Query evaluates an instant query
+func (a *api) Query(ctx context.Context, query string, ts time.Time, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	q, err := a.s.engine.NewInstantQuery(a.s.db, nil, query, ts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exec(ctx, q)
+}
+
+// QueryRange evaluates a range query
+func (a *api) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	q, err := a.s.engine.NewRangeQuery(a.s.db, nil, query, r.Start, r.End, r.Step)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exec(ctx, q)
+}
+
+func (a *api) Alerts(ctx context.Context) (v1.AlertsResult, error) {
+	return v1.AlertsResult{}, errNotSupported
+}
+
+func (a *api) AlertManagers(ctx context.Context) (v1.AlertManagersResult, error) {
+	return v1.AlertManagersResult{}, errNotSupported
+}
+
+func (a *api) CleanTombstones(ctx context.Context) error {
+	return errNotSupported
+}
+
+func (a *api) Config(ctx context.Context) (v1.ConfigResult, error) {
+	return v1.ConfigResult{}, errNotSupported
+}
+
+func (a *api) DeleteSeries(ctx context.Context, matches []string, startTime, endTime time.Time) error {
+	return errNotSupported
+}
+
+func (a *api) Flags(ctx context.Context) (v1.FlagsResult, error) {
+	return nil, errNotSupported
+}
+
+func (a *api) LabelNames(ctx context.Context, matches []string, startTime, endTime time.Time) ([]string, v1.Warnings, error) {
+	return nil, nil, errNotSupported
+}
+
+func (a *api) LabelValues(ctx context.Context, label string, matches []string, startTime, endTime time.Time) (model.LabelValues, v1.Warnings, error) {
+	return nil, nil, errNotSupported
+}
+
+func (a *api) QueryExemplars(ctx context.Context, query string, startTime, endTime time.Time) ([]v1.ExemplarQueryResult, error) {
+	return nil, errNotSupported
+}
+
+func (a *api) Buildinfo(ctx context.Context) (v1.BuildinfoResult, error) {
+	return v1.BuildinfoResult{}, errNotSupported
+}
+
+func (a *api) Runtimeinfo(ctx context.Context) (v1.RuntimeinfoResult, error) {
+	return v1.RuntimeinfoResult{}, errNotSupported
+}
+
+func (a *api) Series(ctx context.Context, matches []string, startTime, endTime time.Time) ([]model.LabelSet, v1.Warnings, error) {
+	return nil, nil, errNotSupported
+}
+
+func (a *api) Snapshot(ctx context.Context, skipHead bool) (v1.SnapshotResult, error) {
+	return v1.SnapshotResult{}, errNotSupported
+}
+
+func (a *api) Rules(ctx context.Context) (v1.RulesResult, error) {
+	return v1.RulesResult{}, errNotSupported
+}
+
+func (a *api) Targets(ctx context.Context) (v1.TargetsResult, error) {
+	return v1.TargetsResult{}, errNotSupported
+}
+
+func (a *api) TargetsMetadata(ctx context.Context, matchTarget, metric, limit string) ([]v1.MetricMetadata, error) {
+	return nil, errNotSupported
+}
+
+func (a *api) Metadata(ctx context.Context, metric, limit string) (map[string][]v1.Metadata, error) {
+	return nil, errNotSupported
+}
+
+func (a *api) TSDB(ctx context.Context) (v1.TSDBResult, error) {
+	return v1.TSDBResult{}, errNotSupported
+}
+
+func (a *api) WalReplay(ctx context.Context) (v1.WalReplayStatus, error) {
+	return v1.WalReplayStatus{}, errNotSupported
+}
+
+// exec runs a query and converts its result to the types returned by the client API
+func exec(ctx context.Context, q promql.Query) (model.Value, v1.Warnings, error) {
+	defer q.Close()
+	res := q.Exec(ctx)
+	var warnings v1.Warnings
+	for _, w := range res.Warnings {
+		warnings = append(warnings, w.Error())
+	}
+	if res.Err != nil {
+		return nil, warnings, res.Err
+	}
+	v, err := convert(res.Value)
+	return v, warnings, err
+}
+
+// convert converts a value of the promql engine to its client API type
+func convert(v interface{}) (model.Value, error) {
+	switch v := v.(type) {
+	case promql.Vector:
+		vec := make(model.Vector, 0, len(v))
+		for _, s := range v {
+			vec = append(vec, &model.Sample{
+				Metric:    metric(s.Metric),
+				Value:     model.SampleValue(s.V),
+				Timestamp: model.Time(s.T),
+			})
+		}
+		return vec, nil
+	case promql.Matrix:
+		m := make(model.Matrix, 0, len(v))
+		for _, s := range v {
+			ss := &model.SampleStream{Metric: metric(s.Metric), Values: make([]model.SamplePair, 0, len(s.Points))}
+			for _, p := range s.Points {
+				ss.Values = append(ss.Values, model.SamplePair{Timestamp: model.Time(p.T), Value: model.SampleValue(p.V)})
+			}
+			m = append(m, ss)
+		}
+		return m, nil
+	case promql.Scalar:
+		return &model.Scalar{Value: model.SampleValue(v.V), Timestamp: model.Time(v.T)}, nil
+	case promql.String:
+		return &model.String{Value: v.V, Timestamp: model.Time(v.T)}, nil
+	}
+	return nil, fmt.Errorf("unsupported result type %T", v)
+}
+
+// metric converts series labels to a client API metric
+func metric(lset labels.Labels) model.Metric {
+	m := make(model.Metric, len(lset))
+	for _, l := range lset {
+		m[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return m
+}
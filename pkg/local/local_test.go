@@ -0,0 +1,140 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+var anchor = time.Unix(1700000000, 0)
+
+func TestParse(t *testing.T) {
+	at := anchor.UnixMilli()
+	tests := []struct {
+		name    string
+		data    string
+		want    []Series
+		wantErr string
+	}{
+		{
+			name: "series notation ends at the anchor",
+			data: `# captured from staging
+load 1m
+  http_requests_total{job="api"} 0+10x2
+  up{job="api"} 1 _ 1 1
+`,
+			want: []Series{
+				{
+					Labels:  labels.FromStrings("__name__", "http_requests_total", "job", "api"),
+					Samples: []Sample{{T: at - 180000, V: 0}, {T: at - 120000, V: 10}, {T: at - 60000, V: 20}},
+				},
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "api"),
+					Samples: []Sample{{T: at - 180000, V: 1}, {T: at - 60000, V: 1}, {T: at, V: 1}},
+				},
+			},
+		},
+		{
+			name:    "invalid load interval",
+			data:    "load soon\n  up 1\n",
+			wantErr: `line 1: invalid load interval "soon"`,
+		},
+		{
+			name: "text exposition",
+			data: `# TYPE up gauge
+up{job="api"} 1
+up{job="db"} 0 1699999940000
+up{job="db"} 1 1699999880000
+`,
+			want: []Series{
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "api"),
+					Samples: []Sample{{T: at, V: 1}},
+				},
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "db"),
+					Samples: []Sample{{T: at - 120000, V: 1}, {T: at - 60000, V: 0}},
+				},
+			},
+		},
+		{
+			name: "openmetrics",
+			data: `# TYPE requests counter
+requests_total{job="api"} 5 1699999970.5
+# EOF
+`,
+			want: []Series{
+				{
+					Labels:  labels.FromStrings("__name__", "requests_total", "job", "api"),
+					Samples: []Sample{{T: at - 29500, V: 5}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data), anchor)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	data := `load 1m
+  http_requests_total{job="api", code="200"} 0+60x10
+  http_requests_total{job="api", code="500"} 0+6x10
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open([]string{path}, anchor, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	api := s.API()
+
+	v, _, err := api.Query(context.Background(), `sum by (code) (rate(http_requests_total[5m]))`, anchor)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Vector{
+		{Metric: model.Metric{"code": "200"}, Value: 1, Timestamp: model.TimeFromUnix(anchor.Unix())},
+		{Metric: model.Metric{"code": "500"}, Value: 0.1, Timestamp: model.TimeFromUnix(anchor.Unix())},
+	}, v)
+
+	v, _, err = api.QueryRange(context.Background(), `http_requests_total{code="500"}`, v1.Range{
+		Start: anchor.Add(-2 * time.Minute),
+		End:   anchor,
+		Step:  time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Matrix{
+		{
+			Metric: model.Metric{"__name__": "http_requests_total", "job": "api", "code": "500"},
+			Values: []model.SamplePair{
+				{Timestamp: model.TimeFromUnix(anchor.Unix() - 120), Value: 48},
+				{Timestamp: model.TimeFromUnix(anchor.Unix() - 60), Value: 54},
+				{Timestamp: model.TimeFromUnix(anchor.Unix()), Value: 60},
+			},
+		},
+	}, v)
+
+	_, _, err = api.Query(context.Background(), `sum(`, anchor)
+	assert.Error(t, err)
+
+	_, err = api.Targets(context.Background())
+	assert.EqualError(t, err, "not supported by eval")
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	rangeCacheKind  = "range"
+	seriesCacheKind = "series"
+	metaCacheKind   = "meta"
+	// cacheFreshness is how long before a range was fetched its samples are fetched again by later queries,
+	// as the newest samples may have been incomplete, e.g. late scrapes or recording rules that hadn't run yet
+	cacheFreshness = 5 * time.Minute
+)
+
+// rangeEntry is a cached range query result
+type rangeEntry struct {
+	Start  time.Time    `json:"start"`
+	End    time.Time    `json:"end"`
+	Matrix model.Matrix `json:"matrix"`
+}
+
+// cacheKey joins the parts identifying a query into a cache key
+func cacheKey(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// cacheScope identifies the server and the credentials used to query it, so clients of a multi-tenant server using
+// different credentials never share results. Credentials are hashed, as cache keys are stored in the entries.
+func (p *PromQL) cacheScope() string {
+	if p.Auth.Type == "" && p.TLSConfig.CertFile == "" {
+		return p.Host
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", p.Auth.Type, string(p.Auth.Credentials), p.Auth.CredentialsFile, p.TLSConfig.CertFile)
+	// Credential files can be rotated to another tenant's token in place, so their contents count too
+	if p.Auth.CredentialsFile != "" {
+		if b, err := os.ReadFile(p.Auth.CredentialsFile); err == nil {
+			h.Write(b)
+		}
+	}
+	return cacheKey(p.Host, hex.EncodeToString(h.Sum(nil)))
+}
+
+// alignTime rounds t down to a multiple of step since the unix epoch
+func alignTime(t time.Time, step time.Duration) time.Time {
+	return t.Add(-time.Duration(t.UnixNano() % int64(step)))
+}
+
+// trimMatrix returns the samples of m between start and end, dropping series left without any
+func trimMatrix(m model.Matrix, start time.Time, end time.Time) model.Matrix {
+	var trimmed model.Matrix
+	from, to := model.TimeFromUnixNano(start.UnixNano()), model.TimeFromUnixNano(end.UnixNano())
+	for _, s := range m {
+		var values []model.SamplePair
+		for _, v := range s.Values {
+			if !v.Timestamp.Before(from) && !v.Timestamp.After(to) {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			trimmed = append(trimmed, &model.SampleStream{Metric: s.Metric, Values: values})
+		}
+	}
+	return trimmed
+}
+
+// cachedRangeQuery runs a range query, reusing any samples of the range cached by earlier queries and only fetching the rest.
+// The range is aligned to the step so that earlier results line up with it.
+func (p *PromQL) cachedRangeQuery(queryString string, r v1.Range) (model.Matrix, v1.Warnings, error) {
+	r.Start, r.End = alignTime(r.Start, r.Step), alignTime(r.End, r.Step)
+	key := cacheKey(p.cacheScope(), queryString, r.Step.String())
+
+	fetch := r
+	var cached model.Matrix
+	var e rangeEntry
+	if created, ok := p.Cache.Get(rangeCacheKind, key, &e); ok {
+		// Only trust samples that were old enough to be complete when they were fetched
+		trusted := e.End
+		if limit := alignTime(created.Add(-cacheFreshness), r.Step); limit.Before(trusted) {
+			trusted = limit
+		}
+		if !e.Start.After(r.Start) && !trusted.Before(r.Start) {
+			if !trusted.Before(r.End) {
+				return trimMatrix(e.Matrix, r.Start, r.End), v1.Warnings{}, nil
+			}
+			cached = trimMatrix(e.Matrix, r.Start, trusted)
+			fetch.Start = trusted.Add(r.Step)
+		}
+	}
+
+	result, warnings, err := p.fetchRange(queryString, fetch)
+	if err != nil {
+		return nil, warnings, err
+	}
+	merged := stitchMatrices([]model.Matrix{cached, result})
+	// Partial results shouldn't be reused, and a failure to cache the result shouldn't fail the query
+	if len(warnings) == 0 {
+		if err := p.Cache.Put(rangeCacheKind, key, rangeEntry{Start: r.Start, End: r.End, Matrix: merged}); err != nil {
+			warnings = append(warnings, fmt.Sprintf("unable to cache result: %v", err))
+		}
+	}
+	return merged, warnings, nil
+}
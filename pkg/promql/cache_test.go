@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nalbury/promql-cli/pkg/cache"
+)
+
+// recordingAPI records the ranges it's asked to query
+type recordingAPI struct {
+	stepAPI
+	mu     sync.Mutex
+	ranges []v1.Range
+}
+
+func (a *recordingAPI) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	a.mu.Lock()
+	a.ranges = append(a.ranges, r)
+	a.mu.Unlock()
+	m, _, err := a.stepAPI.QueryRange(ctx, query, r, opts...)
+	return m, nil, err
+}
+
+func TestCachedRangeQuery(t *testing.T) {
+	c, err := cache.New(t.TempDir(), time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	api := &recordingAPI{}
+	p := PromQL{Host: "http://prometheus", Client: api, TimeoutDuration: time.Second, Cache: c}
+	end := alignTime(time.Now(), time.Minute)
+	r := v1.Range{Start: end.Add(-2 * time.Hour), End: end, Step: time.Minute}
+
+	first, _, err := p.cachedRangeQuery("up", r)
+	assert.NoError(t, err)
+	// The second query only fetches the samples that may have been incomplete the first time
+	second, _, err := p.cachedRangeQuery("up", r)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	if assert.Len(t, api.ranges, 2) {
+		assert.Equal(t, r, api.ranges[0])
+		assert.WithinDuration(t, end.Add(-cacheFreshness+time.Minute), api.ranges[1].Start, 0)
+		assert.WithinDuration(t, end, api.ranges[1].End, 0)
+	}
+
+	// Ranges in the past are served entirely from the cache
+	past := v1.Range{Start: r.Start, End: r.Start.Add(time.Hour), Step: time.Minute}
+	m, _, err := p.cachedRangeQuery("up", past)
+	assert.NoError(t, err)
+	assert.Len(t, api.ranges, 2)
+	if assert.Len(t, m, 2) {
+		assert.Len(t, m[0].Values, 61)
+	}
+
+	// Ranges starting before the cached one, and other steps, are fetched in full
+	_, _, err = p.cachedRangeQuery("up", v1.Range{Start: r.Start.Add(-time.Hour), End: end, Step: time.Minute})
+	assert.NoError(t, err)
+	_, _, err = p.cachedRangeQuery("up", v1.Range{Start: r.Start, End: end, Step: 5 * time.Minute})
+	assert.NoError(t, err)
+	assert.Len(t, api.ranges, 4)
+}
+
+func TestCachedRangeQueryZeroStep(t *testing.T) {
+	c, err := cache.New(t.TempDir(), time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	api := &recordingAPI{}
+	p := PromQL{Host: "http://prometheus", Client: api, TimeoutDuration: time.Second, Cache: c, Start: "1h", End: "now", Step: "0s"}
+	_, _, err = p.RangeQuery("up")
+	assert.EqualError(t, err, "step duration must be greater than 0, got 0s")
+	assert.Empty(t, api.ranges)
+}
+
+func TestCacheScope(t *testing.T) {
+	c, err := cache.New(t.TempDir(), time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	api := &recordingAPI{}
+	tenant := func(credentials string) *PromQL {
+		return &PromQL{
+			Host:            "http://mimir",
+			Client:          api,
+			TimeoutDuration: time.Second,
+			Cache:           c,
+			Auth:            config.Authorization{Type: "Bearer", Credentials: config.Secret(credentials)},
+		}
+	}
+	end := alignTime(time.Now(), time.Minute).Add(-time.Hour)
+	r := v1.Range{Start: end.Add(-time.Hour), End: end, Step: time.Minute}
+
+	// Each tenant fetches the range once, and never gets the results cached for the other
+	for _, p := range []*PromQL{tenant("a"), tenant("b"), tenant("a"), tenant("b")} {
+		_, _, err := p.cachedRangeQuery("up", r)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, api.ranges, 2)
+	assert.NotEqual(t, tenant("a").cacheScope(), tenant("b").cacheScope())
+	assert.NotContains(t, tenant("a").cacheScope(), "Bearer")
+	assert.Equal(t, "http://mimir", (&PromQL{Host: "http://mimir"}).cacheScope())
+}
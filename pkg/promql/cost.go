@@ -0,0 +1,211 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// DefaultLookbackDelta is how far back prometheus looks for the latest sample of an instant vector selector
+	DefaultLookbackDelta = 5 * time.Minute
+	// DefaultSubqueryStep is the step of subqueries that don't set one, prometheus' default evaluation interval
+	DefaultSubqueryStep = time.Minute
+)
+
+// SelectorCost is the estimated cost of a single vector selector of a query
+type SelectorCost struct {
+	// Selector is the selector as written in the query, including its range, offset and @ modifiers
+	Selector string `json:"selector"`
+	// Series is the number of series the selector matches over the time it's evaluated for
+	Series int `json:"series"`
+	// Range is the range of a range vector selector, zero for instant vector selectors
+	Range time.Duration `json:"range"`
+	// Evaluations is the number of times the selector is evaluated, once per step including the steps of subqueries
+	Evaluations int64 `json:"evaluations"`
+	// Samples is the estimated number of samples read by all evaluations of the selector
+	Samples int64 `json:"samples"`
+}
+
+// Cost is the estimated cost of evaluating a query
+type Cost struct {
+	Start          time.Time      `json:"start"`
+	End            time.Time      `json:"end"`
+	Step           time.Duration  `json:"step"`
+	ScrapeInterval time.Duration  `json:"scrape_interval"`
+	Selectors      []SelectorCost `json:"selectors"`
+	Samples        int64          `json:"samples"`
+}
+
+// costSelector is a vector selector along with how it's evaluated within the query
+type costSelector struct {
+	vs *parser.VectorSelector
+	// expr is the selector as written in the query, the range vector selector for range vectors
+	expr parser.Expr
+	// rng is the range of the selector's range vector, zero for instant vector selectors
+	rng time.Duration
+	// evals is the number of times the selector is evaluated per step of the query, more than one within subqueries
+	evals int64
+	// lookback is how far before each step samples are read from, including the range of enclosing subqueries
+	lookback time.Duration
+	// offset is the total offset of the selector and enclosing subqueries
+	offset time.Duration
+	// fixed is set if the selector or an enclosing subquery has an @ modifier, so it's only evaluated once
+	fixed *time.Time
+}
+
+// costSelectors returns every vector selector of an expression along with how it's evaluated
+func costSelectors(expr parser.Expr) []costSelector {
+	var selectors []costSelector
+	var walk func(node parser.Node, parent costSelector)
+	walk = func(node parser.Node, parent costSelector) {
+		switch n := node.(type) {
+		case *parser.MatrixSelector:
+			if vs, ok := n.VectorSelector.(*parser.VectorSelector); ok {
+				s := parent
+				s.rng = n.Range
+				s.lookback += n.Range
+				s = s.with(vs)
+				s.expr = n
+				selectors = append(selectors, s)
+			}
+			return
+		case *parser.VectorSelector:
+			s := parent
+			s.lookback += DefaultLookbackDelta
+			selectors = append(selectors, s.with(n))
+			return
+		case *parser.SubqueryExpr:
+			step := n.Step
+			if step <= 0 {
+				step = DefaultSubqueryStep
+			}
+			s := parent
+			if evals := int64(n.Range / step); evals > 1 {
+				s.evals *= evals
+			}
+			s.lookback += n.Range
+			s.offset += n.OriginalOffset
+			s.fixed = fixedTime(n.Timestamp, s.fixed)
+			walk(n.Expr, s)
+			return
+		}
+		for _, child := range parser.Children(node) {
+			walk(child, parent)
+		}
+	}
+	walk(expr, costSelector{evals: 1})
+	return selectors
+}
+
+// with returns a copy of the selector's context for vs, applying its own offset and @ modifiers
+func (s costSelector) with(vs *parser.VectorSelector) costSelector {
+	s.vs = vs
+	s.expr = vs
+	s.offset += vs.OriginalOffset
+	s.fixed = fixedTime(vs.Timestamp, s.fixed)
+	return s
+}
+
+// fixedTime returns the time of an @ modifier, or the time of an enclosing one if it doesn't have one
+func fixedTime(ts *int64, parent *time.Time) *time.Time {
+	if ts == nil {
+		return parent
+	}
+	t := time.UnixMilli(*ts)
+	return &t
+}
+
+// selector returns the selector without its offset and @ modifiers, for looking up its series
+func (s costSelector) selector() string {
+	vs := *s.vs
+	vs.OriginalOffset = 0
+	vs.Offset = 0
+	vs.Timestamp = nil
+	vs.StartOrEnd = 0
+	return vs.String()
+}
+
+// CostQuery estimates the number of samples a parsed query would read, by counting the series matched by each of its vector selectors
+// over the time they're evaluated for. Range queries use the --start/--end range and step, instant queries are evaluated once at --time.
+func (p *PromQL) CostQuery(expr parser.Expr) (Cost, error) {
+	var c Cost
+	c.Start, c.End = p.Time, p.Time
+	if p.Start != "" {
+		r, err := p.getRange()
+		if err != nil {
+			return c, err
+		}
+		c.Start, c.End, c.Step = r.Start, r.End, r.Step
+	}
+	steps := int64(1)
+	if c.Step > 0 {
+		steps = int64(c.End.Sub(c.Start)/c.Step) + 1
+	}
+	c.ScrapeInterval = p.ScrapeInterval
+	if c.ScrapeInterval <= 0 {
+		c.ScrapeInterval = DefaultScrapeInterval
+	}
+
+	// The same selector over the same time is only counted once
+	counts := make(map[string]int)
+	for _, s := range costSelectors(expr) {
+		start, end := c.Start, c.End
+		evals := s.evals * steps
+		if s.fixed != nil {
+			start, end = *s.fixed, *s.fixed
+			evals = s.evals
+		}
+		start = start.Add(-s.offset - s.lookback)
+		end = end.Add(-s.offset)
+		selector := s.selector()
+		key := cacheKey(selector, start.String(), end.String())
+		n, ok := counts[key]
+		if !ok {
+			sp := *p
+			sp.Start = start.Format(time.RFC3339Nano)
+			sp.End = end.Format(time.RFC3339Nano)
+			series, _, err := sp.SeriesQuery(selector)
+			if err != nil {
+				return c, fmt.Errorf("unable to count the series of %s, %v", selector, err)
+			}
+			n = len(series)
+			counts[key] = n
+		}
+		// Instant vector selectors read the latest sample of each series, range vector selectors every sample in their range
+		perEval := int64(1)
+		if s.rng > 0 {
+			perEval = int64(s.rng / c.ScrapeInterval)
+			if perEval < 1 {
+				perEval = 1
+			}
+		}
+		sc := SelectorCost{
+			Selector:    s.expr.String(),
+			Series:      n,
+			Range:       s.rng,
+			Evaluations: evals,
+			Samples:     int64(n) * evals * perEval,
+		}
+		c.Selectors = append(c.Selectors, sc)
+		c.Samples += sc.Samples
+	}
+	return c, nil
+}
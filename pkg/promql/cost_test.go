@@ -0,0 +1,92 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// seriesAPI returns a fixed number of series for each selector, and records the range each was requested for
+type seriesAPI struct {
+	v1.API
+	series map[string]int
+	ranges map[string][2]time.Time
+}
+
+func (a *seriesAPI) Series(ctx context.Context, matches []string, startTime time.Time, endTime time.Time) ([]model.LabelSet, v1.Warnings, error) {
+	var result []model.LabelSet
+	for _, m := range matches {
+		a.ranges[m] = [2]time.Time{startTime, endTime}
+		for i := 0; i < a.series[m]; i++ {
+			result = append(result, model.LabelSet{"selector": model.LabelValue(m), "i": model.LabelValue(rune('a' + i))})
+		}
+	}
+	return result, nil, nil
+}
+
+func TestCostQuery(t *testing.T) {
+	api := &seriesAPI{
+		series: map[string]int{`http_requests_total{code="500"}`: 10, "up": 4, "node_load1": 2},
+		ranges: make(map[string][2]time.Time),
+	}
+	end := time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC)
+	p := PromQL{
+		Client:   api,
+		Start:    end.Add(-time.Hour).Format(time.RFC3339),
+		End:      end.Format(time.RFC3339),
+		Step:     "1m",
+		Location: time.UTC,
+	}
+	expr, err := parser.ParseExpr(`sum(rate(http_requests_total{code="500"}[5m] offset 1h)) / count(up) + max_over_time(node_load1[30m:5m])`)
+	assert.NoError(t, err)
+	c, err := p.CostQuery(expr)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, c.Step)
+	assert.Equal(t, DefaultScrapeInterval, c.ScrapeInterval)
+	assert.Equal(t, []SelectorCost{
+		// 61 steps reading the 20 samples in 5m of 10 series
+		{Selector: `http_requests_total{code="500"}[5m] offset 1h`, Series: 10, Range: 5 * time.Minute, Evaluations: 61, Samples: 12200},
+		// 61 steps reading the latest sample of 4 series
+		{Selector: "up", Series: 4, Evaluations: 61, Samples: 244},
+		// 61 steps of 6 subquery steps reading the latest sample of 2 series
+		{Selector: "node_load1", Series: 2, Evaluations: 366, Samples: 732},
+	}, c.Selectors)
+	assert.Equal(t, int64(12200+244+732), c.Samples)
+
+	// Series are counted over the time the selector reads samples from, including offsets, ranges and lookback
+	assert.Equal(t, [2]time.Time{end.Add(-2*time.Hour - 5*time.Minute), end.Add(-time.Hour)}, api.ranges[`http_requests_total{code="500"}`])
+	assert.Equal(t, [2]time.Time{end.Add(-time.Hour - DefaultLookbackDelta), end}, api.ranges["up"])
+	assert.Equal(t, [2]time.Time{end.Add(-time.Hour - 30*time.Minute - DefaultLookbackDelta), end}, api.ranges["node_load1"])
+
+	// Instant queries are evaluated once, and selectors with an @ modifier are only evaluated once for the whole range
+	at := end.Add(-24 * time.Hour)
+	instant := PromQL{Client: api, Time: end, End: "now", Location: time.UTC}
+	expr, err = parser.ParseExpr(`up @ ` + fmt.Sprint(at.Unix()))
+	assert.NoError(t, err)
+	c, err = instant.CostQuery(expr)
+	assert.NoError(t, err)
+	assert.Equal(t, []SelectorCost{{Selector: "up @ " + fmt.Sprintf("%d.000", at.Unix()), Series: 4, Evaluations: 1, Samples: 4}}, c.Selectors)
+	assert.Equal(t, [2]time.Time{at.Add(-DefaultLookbackDelta), at}, api.ranges["up"])
+}
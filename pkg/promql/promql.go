@@ -19,15 +19,22 @@ package promql
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
+
+	"github.com/nalbury/promql-cli/pkg/cache"
+	"github.com/nalbury/promql-cli/pkg/util"
 ) // Client is our prometheus v1 API interface
 type Client interface {
 	v1.API
@@ -41,7 +48,7 @@ func CreateClient(host string) (v1.API, error) {
 	if err != nil {
 		return nil, err
 	}
-	return v1.NewAPI(a), nil
+	return &httpAPI{API: v1.NewAPI(a), client: a}, nil
 }
 
 // CreateClientWithAuth creates a Client interface witht the provided hostname and auth config
@@ -80,7 +87,91 @@ func CreateClientWithAuth(host string, authCfg config.Authorization, tlsCfg conf
 	if err != nil {
 		return nil, err
 	}
-	return v1.NewAPI(a), nil
+	return &httpAPI{API: v1.NewAPI(a), client: a}, nil
+}
+
+// httpAPI wraps the v1.API to fill in gaps in the upstream client, such as
+// decoding string results and passing a limit to the TSDB stats endpoint
+type httpAPI struct {
+	v1.API
+	client api.Client
+}
+
+// apiResponse is the envelope of every prometheus API response
+type apiResponse struct {
+	Status   string          `json:"status"`
+	Data     json.RawMessage `json:"data"`
+	Error    string          `json:"error"`
+	Warnings v1.Warnings     `json:"warnings"`
+}
+
+// do performs a request against the prometheus API and returns the raw data of the response
+func (h *httpAPI) do(ctx context.Context, method string, path string, args url.Values) (json.RawMessage, v1.Warnings, error) {
+	u := h.client.URL(path, nil)
+	var (
+		req *http.Request
+		err error
+	)
+	if method == http.MethodPost {
+		req, err = http.NewRequest(method, u.String(), strings.NewReader(args.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		u.RawQuery = args.Encode()
+		req, err = http.NewRequest(method, u.String(), nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_, body, err := h.client.Do(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp apiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, nil, err
+	}
+	if resp.Status != "success" {
+		return nil, resp.Warnings, fmt.Errorf("%s", resp.Error)
+	}
+	return resp.Data, resp.Warnings, nil
+}
+
+// Query performs an instant query, falling back to decoding the response ourselves for string results
+func (h *httpAPI) Query(ctx context.Context, query string, ts time.Time, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	result, warnings, err := h.API.Query(ctx, query, ts, opts...)
+	if err == nil || !strings.Contains(err.Error(), `unexpected value type "string"`) {
+		return result, warnings, err
+	}
+
+	args := url.Values{}
+	args.Set("query", query)
+	args.Set("time", strconv.FormatFloat(float64(ts.UnixNano())/1e9, 'f', -1, 64))
+	data, warnings, err := h.do(ctx, http.MethodPost, "/api/v1/query", args)
+	if err != nil {
+		return nil, warnings, err
+	}
+	var qr struct {
+		Result model.String `json:"result"`
+	}
+	if err := json.Unmarshal(data, &qr); err != nil {
+		return nil, warnings, err
+	}
+	return &qr.Result, warnings, nil
+}
+
+// TSDBWithLimit returns the TSDB stats with up to limit entries in each list.
+// Servers older than v2.42 ignore the limit and return the top 10.
+func (h *httpAPI) TSDBWithLimit(ctx context.Context, limit int) (v1.TSDBResult, error) {
+	args := url.Values{}
+	args.Set("limit", strconv.Itoa(limit))
+	data, _, err := h.do(ctx, http.MethodGet, "/api/v1/status/tsdb", args)
+	if err != nil {
+		return v1.TSDBResult{}, err
+	}
+	var res v1.TSDBResult
+	return res, json.Unmarshal(data, &res)
 }
 
 // Cfg conatins the final configuration params parsed from a combo of flags, config file values, and env vars.
@@ -94,13 +185,34 @@ type PromQL struct {
 	Start           string
 	End             string
 	NoHeaders       bool
+	Exemplars       bool
 	Auth            config.Authorization
 	Client          v1.API
 	TLSConfig       config.TLSConfig
+	// Points is the number of points per series to aim for when deriving the step of a range query, DefaultPoints if unset
+	Points int
+	// Split is the length of the chunks to split range queries into, or auto to split them to fit the server's point limit.
+	// Range queries aren't split if it's empty.
+	Split string
+	// SplitConcurrency is the number of chunks of a split range query to run at once, DefaultSplitConcurrency if unset
+	SplitConcurrency int
+	// Cache stores query results for reuse by later queries, results aren't cached if it's nil
+	Cache *cache.Cache
+	// Location is the timezone used to parse times without one, e.g. 2006-01-02 or 15:04
+	Location *time.Location
+	// Vars are the values of the $var variables substituted into queries by Expand
+	Vars map[string]string
+	// ScrapeInterval is the scrape interval $__rate_interval is derived from, DefaultScrapeInterval if unset
+	ScrapeInterval time.Duration
 }
 
 // InstantQuery performs an instant query and returns the result
-func (p *PromQL) InstantQuery(queryString string) (model.Vector, v1.Warnings, error) {
+// The result is a model.Vector, model.Matrix, *model.Scalar or *model.String depending on the expression
+func (p *PromQL) InstantQuery(queryString string) (model.Value, v1.Warnings, error) {
+	queryString, err := p.Expand(queryString)
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
 	defer cancel()
 
@@ -108,73 +220,76 @@ func (p *PromQL) InstantQuery(queryString string) (model.Vector, v1.Warnings, er
 	if err != nil {
 		return nil, warnings, fmt.Errorf("error querying prometheus: %v", err)
 	}
-
-	if result, ok := result.(model.Vector); ok {
-		return result, warnings, nil
-	} else {
-		return nil, warnings, fmt.Errorf("did not receive an instant vector result")
-	}
-}
-
-func parseRangeStart(s string) (time.Time, error) {
-	if t, err := time.Parse(time.RFC3339, s); err == nil {
-		return t, nil
-	} else if d, err := time.ParseDuration(s); err == nil {
-		return time.Now().Add(-d), nil
-	} else {
-		err = fmt.Errorf("unable to parse range start time, %v", err)
-		return time.Time{}, nil
-	}
+	return result, warnings, nil
 }
 
-func parseRangeEnd(e string) (time.Time, error) {
-	if e == "now" {
-		return time.Now(), nil
-	}
-	t, err := time.Parse(time.RFC3339, e)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("error parsing range end time, %v", err)
-	}
-	return t, nil
+// parseTime parses a --time, --start or --end value in the configured timezone
+func (p *PromQL) parseTime(s string) (time.Time, error) {
+	return util.ParseTime(s, time.Now(), p.Location)
 }
 
 // getRange creates a prometheus range from the provided start, end, and step options
 func (p *PromQL) getRange() (r v1.Range, err error) {
 	// At minimum we need a start time so we attempt to parse that first
-	r.Start, err = parseRangeStart(p.Start)
+	r.Start, err = p.parseTime(p.Start)
 	if err != nil {
-		return r, err
+		return r, fmt.Errorf("unable to parse range start time, %v", err)
 	}
-	// Set up defaults for the step value
-	r.Step = time.Minute
-	// If the user provided a step value, parse it as a time.Duration and override the default
-	if p.Step != "" {
-		r.Step, err = time.ParseDuration(p.Step)
-		if err != nil {
-			err = fmt.Errorf("unable to parse step duration, %v", err)
-			return r, err
-		}
+	// If the user provided an end value, parse it to a time struct and override the default
+	r.End, err = p.parseTime(p.End)
+	if err != nil {
+		return r, fmt.Errorf("error parsing range end time, %v", err)
 	}
 
-	// If the user provided an end value, parse it to a time struct and override the default
-	r.End, err = parseRangeEnd(p.End)
+	// Without a step value, or with a step of auto, derive the step from the range and the number of points we want
+	if p.Step == "" || p.Step == AutoStep {
+		r.Step = Step(r.End.Sub(r.Start), p.Points)
+		return r, nil
+	}
+	r.Step, err = util.ParseDuration(p.Step)
 	if err != nil {
+		err = fmt.Errorf("unable to parse step duration, %v", err)
 		return r, err
 	}
-
-	return r, err
+	// Ranges are split and cached in multiples of the step, which only works if it moves forward
+	if r.Step <= 0 {
+		return r, fmt.Errorf("step duration must be greater than 0, got %s", p.Step)
+	}
+	return r, nil
 }
 
 // rangeQuery performs a range query and writes the results to stdout
 func (p *PromQL) RangeQuery(queryString string) (model.Matrix, v1.Warnings, error) {
-	// create context with a timeout,
-	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
-	defer cancel()
-
 	r, err := p.getRange()
 	if err != nil {
 		return nil, nil, err
 	}
+	queryString, err = p.Expand(queryString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Cache != nil {
+		return p.cachedRangeQuery(queryString, r)
+	}
+	return p.fetchRange(queryString, r)
+}
+
+// fetchRange queries the server for the provided range
+func (p *PromQL) fetchRange(queryString string, r v1.Range) (model.Matrix, v1.Warnings, error) {
+	// Split the range into chunks if asked to, ranges that fit in a single chunk are queried as normal
+	size, err := p.splitSize(r.Step)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size > 0 {
+		if chunks := splitRange(r, size); len(chunks) > 1 {
+			return p.splitRangeQuery(queryString, chunks)
+		}
+	}
+
+	// create context with a timeout,
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
 	// execute query
 	result, warnings, err := p.Client.QueryRange(ctx, queryString, r)
 	if err != nil {
@@ -188,8 +303,34 @@ func (p *PromQL) RangeQuery(queryString string) (model.Matrix, v1.Warnings, erro
 	}
 }
 
+// ExemplarsQuery returns the exemplars of the series selected by the query over the --start/--end range
+func (p *PromQL) ExemplarsQuery(queryString string) ([]v1.ExemplarQueryResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	if p.Start == "" {
+		return nil, fmt.Errorf("a range start is required for exemplar queries, set one with --start")
+	}
+	r, err := p.getRange()
+	if err != nil {
+		return nil, err
+	}
+	queryString, err = p.Expand(queryString)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.Client.QueryExemplars(ctx, queryString, r.Start, r.End)
+	if err != nil {
+		return nil, fmt.Errorf("error querying exemplars endpoint: %v", err)
+	}
+	return result, nil
+}
+
 // LabelsQuery runs a labels query and returns the result
 func (p *PromQL) LabelsQuery(query string) (model.Vector, v1.Warnings, error) {
+	query, err := p.Expand(query)
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
 	defer cancel()
 
@@ -212,38 +353,207 @@ func (p *PromQL) MetaQuery(query string) (map[string][]v1.Metadata, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
 	defer cancel()
 
+	key := cacheKey(p.cacheScope(), query)
+	if p.Cache != nil {
+		var cached map[string][]v1.Metadata
+		if p.Cache.GetFresh(metaCacheKind, key, &cached) {
+			return cached, nil
+		}
+	}
 	result, err := p.Client.Metadata(ctx, query, "")
 	if err != nil {
 		return map[string][]v1.Metadata{}, fmt.Errorf("Error querying metadata endpoint: %v", err)
 	}
+	if p.Cache != nil {
+		// A failure to cache the result shouldn't fail the query, and there's no warnings to surface it in
+		_ = p.Cache.Put(metaCacheKind, key, result)
+	}
 	return result, nil
 }
 
-// SeriesQuery returns prometheus series data
-func (p *PromQL) SeriesQuery(query string) ([]model.LabelSet, v1.Warnings, error) {
+// seriesRange returns the start and end times used by the series and label endpoints
+func (p *PromQL) seriesRange() (s time.Time, e time.Time, err error) {
 	// Set defaults based on time flag
-	s := p.Time.Add(-15 * time.Second)
-	e := p.Time
+	s = p.Time.Add(-15 * time.Second)
+	e = p.Time
 	// Parse range start and end if provided and override the defaults
 	if p.Start != "" {
-		var err error
-		s, err = parseRangeStart(p.Start)
+		s, err = p.parseTime(p.Start)
 		if err != nil {
-			return []model.LabelSet{}, v1.Warnings{}, err
+			return s, e, fmt.Errorf("unable to parse range start time, %v", err)
 		}
 	}
 	if p.End != "" {
-		var err error
-		e, err = parseRangeEnd(p.End)
+		e, err = p.parseTime(p.End)
 		if err != nil {
-			return []model.LabelSet{}, v1.Warnings{}, err
+			return s, e, fmt.Errorf("error parsing range end time, %v", err)
+		}
+	}
+	return s, e, nil
+}
+
+// SeriesQuery returns the label sets of all series matching any of the provided selectors
+func (p *PromQL) SeriesQuery(matchers ...string) ([]model.LabelSet, v1.Warnings, error) {
+	s, e, err := p.seriesRange()
+	if err != nil {
+		return []model.LabelSet{}, v1.Warnings{}, err
+	}
+	if matchers, err = p.expandMatchers(matchers); err != nil {
+		return []model.LabelSet{}, v1.Warnings{}, err
+	}
+	// Round the range to the TTL, otherwise a default or relative range would never be reused
+	key := cacheKey(append([]string{p.cacheScope()}, matchers...)...)
+	if p.Cache != nil {
+		key = cacheKey(key, s.Truncate(p.Cache.TTL).String(), e.Truncate(p.Cache.TTL).String())
+		var cached []model.LabelSet
+		if p.Cache.GetFresh(seriesCacheKind, key, &cached) {
+			return cached, v1.Warnings{}, nil
 		}
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
 	defer cancel()
-	result, warnings, err := p.Client.Series(ctx, []string{query}, s, e)
+	result, warnings, err := p.Client.Series(ctx, matchers, s, e)
 	if err != nil {
 		return []model.LabelSet{}, warnings, fmt.Errorf("error querying series endpoint: %v", err)
 	}
+	// Partial results shouldn't be reused, and a failure to cache the result shouldn't fail the query
+	if p.Cache != nil && len(warnings) == 0 {
+		if err := p.Cache.Put(seriesCacheKind, key, result); err != nil {
+			warnings = append(warnings, fmt.Sprintf("unable to cache result: %v", err))
+		}
+	}
 	return result, warnings, err
 }
+
+// LabelNamesQuery returns the label names for series matching the provided selectors
+func (p *PromQL) LabelNamesQuery(matches []string) ([]string, v1.Warnings, error) {
+	s, e, err := p.seriesRange()
+	if err != nil {
+		return []string{}, v1.Warnings{}, err
+	}
+	if matches, err = p.expandMatchers(matches); err != nil {
+		return []string{}, v1.Warnings{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, warnings, err := p.Client.LabelNames(ctx, matches, s, e)
+	if err != nil {
+		return []string{}, warnings, fmt.Errorf("error querying labels endpoint: %v", err)
+	}
+	return result, warnings, nil
+}
+
+// LabelValuesQuery returns the values of a label for series matching the provided selectors
+func (p *PromQL) LabelValuesQuery(label string, matches []string) (model.LabelValues, v1.Warnings, error) {
+	s, e, err := p.seriesRange()
+	if err != nil {
+		return model.LabelValues{}, v1.Warnings{}, err
+	}
+	if matches, err = p.expandMatchers(matches); err != nil {
+		return model.LabelValues{}, v1.Warnings{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, warnings, err := p.Client.LabelValues(ctx, label, matches, s, e)
+	if err != nil {
+		return model.LabelValues{}, warnings, fmt.Errorf("error querying label values endpoint: %v", err)
+	}
+	return result, warnings, nil
+}
+
+// TargetsQuery returns the active scrape targets
+func (p *PromQL) TargetsQuery() ([]v1.ActiveTarget, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Targets(ctx)
+	if err != nil {
+		return []v1.ActiveTarget{}, fmt.Errorf("error querying targets endpoint: %v", err)
+	}
+	return result.Active, nil
+}
+
+// AlertsQuery returns the active alerts
+func (p *PromQL) AlertsQuery() ([]v1.Alert, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Alerts(ctx)
+	if err != nil {
+		return []v1.Alert{}, fmt.Errorf("error querying alerts endpoint: %v", err)
+	}
+	return result.Alerts, nil
+}
+
+// RulesQuery returns the recording and alerting rule groups
+func (p *PromQL) RulesQuery() ([]v1.RuleGroup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Rules(ctx)
+	if err != nil {
+		return []v1.RuleGroup{}, fmt.Errorf("error querying rules endpoint: %v", err)
+	}
+	return result.Groups, nil
+}
+
+// CardinalityQuery returns the TSDB stats used for cardinality analysis, with up to limit entries in each list.
+// A limit of 0 uses the server default.
+func (p *PromQL) CardinalityQuery(limit int) (v1.TSDBResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	var (
+		result v1.TSDBResult
+		err    error
+	)
+	if h, ok := p.Client.(*httpAPI); ok && limit > 0 {
+		result, err = h.TSDBWithLimit(ctx, limit)
+	} else {
+		result, err = p.Client.TSDB(ctx)
+	}
+	if err != nil {
+		return v1.TSDBResult{}, fmt.Errorf("error querying tsdb status endpoint: %v", err)
+	}
+	return result, nil
+}
+
+// BuildinfoQuery returns the version and build information of the server
+func (p *PromQL) BuildinfoQuery() (v1.BuildinfoResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Buildinfo(ctx)
+	if err != nil {
+		return v1.BuildinfoResult{}, fmt.Errorf("error querying buildinfo endpoint: %v", err)
+	}
+	return result, nil
+}
+
+// RuntimeinfoQuery returns the runtime information of the server, e.g. start time and storage retention
+func (p *PromQL) RuntimeinfoQuery() (v1.RuntimeinfoResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Runtimeinfo(ctx)
+	if err != nil {
+		return v1.RuntimeinfoResult{}, fmt.Errorf("error querying runtimeinfo endpoint: %v", err)
+	}
+	return result, nil
+}
+
+// FlagsQuery returns the flag values the server was started with
+func (p *PromQL) FlagsQuery() (v1.FlagsResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Flags(ctx)
+	if err != nil {
+		return v1.FlagsResult{}, fmt.Errorf("error querying flags endpoint: %v", err)
+	}
+	return result, nil
+}
+
+// ConfigQuery returns the currently loaded configuration file of the server
+func (p *PromQL) ConfigQuery() (v1.ConfigResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.TimeoutDuration)
+	defer cancel()
+	result, err := p.Client.Config(ctx)
+	if err != nil {
+		return v1.ConfigResult{}, fmt.Errorf("error querying config endpoint: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+
+	"github.com/nalbury/promql-cli/pkg/util"
+)
+
+const (
+	// AutoSplit splits range queries into chunks of as many points as the server allows
+	AutoSplit = "auto"
+	// DefaultSplitConcurrency is the number of chunks of a split range query to run at once when SplitConcurrency isn't set
+	DefaultSplitConcurrency = 4
+)
+
+// splitSize returns the length of each chunk to split a range query with the provided step into, 0 if it shouldn't be split
+func (p *PromQL) splitSize(step time.Duration) (time.Duration, error) {
+	switch p.Split {
+	case "":
+		return 0, nil
+	case AutoSplit:
+		return time.Duration(MaxPoints-1) * step, nil
+	}
+	d, err := util.ParseDuration(p.Split)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse split duration, %v", err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("split duration must not be negative, got %s", p.Split)
+	}
+	return d, nil
+}
+
+// splitRange splits r into consecutive chunks no longer than size. Every chunk starts on a step of the original range,
+// so the chunks return exactly the same points as a single query would.
+func splitRange(r v1.Range, size time.Duration) []v1.Range {
+	// Each chunk covers at least one step, and ends a step before the next one starts so no point is fetched twice
+	steps := size / r.Step
+	if steps < 1 {
+		steps = 1
+	}
+	var chunks []v1.Range
+	for start := r.Start; !start.After(r.End); start = start.Add(steps * r.Step) {
+		end := start.Add((steps - 1) * r.Step)
+		if end.After(r.End) {
+			end = r.End
+		}
+		chunks = append(chunks, v1.Range{Start: start, End: end, Step: r.Step})
+	}
+	return chunks
+}
+
+// stitchMatrices merges the results of consecutive chunks of a range query into one matrix, joining series by fingerprint
+func stitchMatrices(matrices []model.Matrix) model.Matrix {
+	var result model.Matrix
+	index := make(map[model.Fingerprint]*model.SampleStream)
+	for _, m := range matrices {
+		for _, s := range m {
+			fp := s.Metric.Fingerprint()
+			stream, ok := index[fp]
+			if !ok {
+				stream = &model.SampleStream{Metric: s.Metric}
+				index[fp] = stream
+				result = append(result, stream)
+			}
+			stream.Values = append(stream.Values, s.Values...)
+		}
+	}
+	return result
+}
+
+// splitRangeQuery runs a range query as a set of chunks, running up to SplitConcurrency at once, and stitches the results back together
+func (p *PromQL) splitRangeQuery(queryString string, chunks []v1.Range) (model.Matrix, v1.Warnings, error) {
+	concurrency := p.SplitConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultSplitConcurrency
+	}
+	// Stop running chunks once any of them fails, the result would be incomplete anyway
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		warnings v1.Warnings
+		seen     = make(map[string]bool)
+		results  = make([]model.Matrix, len(chunks))
+		sem      = make(chan struct{}, concurrency)
+	)
+	for i, c := range chunks {
+		wg.Add(1)
+		go func(i int, c v1.Range) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			// Each chunk gets the full timeout, which is what lets a split query finish when a single one would time out
+			chunkCtx, chunkCancel := context.WithTimeout(ctx, p.TimeoutDuration)
+			defer chunkCancel()
+			result, w, err := p.Client.QueryRange(chunkCtx, queryString, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, warning := range w {
+				if !seen[warning] {
+					seen[warning] = true
+					warnings = append(warnings, warning)
+				}
+			}
+			if err == nil {
+				if m, ok := result.(model.Matrix); ok {
+					results[i] = m
+					return
+				}
+				err = fmt.Errorf("did not receive a range result")
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error querying range %s to %s: %v", c.Start.Format(time.RFC3339), c.End.Format(time.RFC3339), err)
+				cancel()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, warnings, firstErr
+	}
+	return stitchMatrices(results), warnings, nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// stepAPI answers range queries with a sample for every step of the range, failing any range that starts at failAt
+type stepAPI struct {
+	v1.API
+	failAt time.Time
+}
+
+func (a *stepAPI) QueryRange(ctx context.Context, query string, r v1.Range, opts ...v1.Option) (model.Value, v1.Warnings, error) {
+	if r.Start.Equal(a.failAt) {
+		return nil, nil, fmt.Errorf("query timed out")
+	}
+	var m model.Matrix
+	for _, job := range []string{"api", "node"} {
+		s := &model.SampleStream{Metric: model.Metric{"job": model.LabelValue(job)}}
+		for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+			s.Values = append(s.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(t.UnixNano()), Value: 1})
+		}
+		m = append(m, s)
+	}
+	return m, v1.Warnings{"partial response"}, nil
+}
+
+func TestSplitRange(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := v1.Range{Start: start, End: start.Add(10 * time.Minute), Step: time.Minute}
+	cases := []struct {
+		Size     time.Duration
+		Expected []v1.Range
+	}{
+		{
+			Size: 5 * time.Minute,
+			Expected: []v1.Range{
+				{Start: start, End: start.Add(4 * time.Minute), Step: time.Minute},
+				{Start: start.Add(5 * time.Minute), End: start.Add(9 * time.Minute), Step: time.Minute},
+				{Start: start.Add(10 * time.Minute), End: start.Add(10 * time.Minute), Step: time.Minute},
+			},
+		},
+		{
+			Size:     time.Hour,
+			Expected: []v1.Range{r},
+		},
+	}
+	for i, c := range cases {
+		assert.Equal(t, c.Expected, splitRange(r, c.Size), "Unexpected chunks for case %d", i)
+	}
+	// Chunks are never shorter than a step
+	assert.Len(t, splitRange(r, time.Second), 11)
+}
+
+func TestSplitRangeQuery(t *testing.T) {
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Minute)
+	r := v1.Range{Start: start, End: start.Add(3 * time.Hour), Step: time.Minute}
+	p := PromQL{Client: &stepAPI{}, TimeoutDuration: time.Second, SplitConcurrency: 2}
+
+	whole, _, err := p.Client.QueryRange(context.Background(), "up", r)
+	assert.NoError(t, err)
+	split, warnings, err := p.splitRangeQuery("up", splitRange(r, 25*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, whole, split)
+	assert.Equal(t, v1.Warnings{"partial response"}, warnings)
+
+	p.Client = &stepAPI{failAt: start.Add(50 * time.Minute)}
+	_, _, err = p.splitRangeQuery("up", splitRange(r, 25*time.Minute))
+	assert.Error(t, err)
+}
+
+func TestSplitSize(t *testing.T) {
+	p := PromQL{Split: "1h"}
+	d, err := p.splitSize(time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+
+	p.Split = AutoSplit
+	d, err = p.splitSize(time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(MaxPoints-1)*time.Minute, d)
+
+	p.Split = "-1h"
+	_, err = p.splitSize(time.Minute)
+	assert.EqualError(t, err, "split duration must not be negative, got -1h")
+}
+
+func TestSplitRangeQueryInvalidStep(t *testing.T) {
+	for _, step := range []string{"0s", "-1m"} {
+		p := PromQL{Client: &stepAPI{}, Start: "1d", End: "now", Step: step, Split: "1h", TimeoutDuration: time.Second}
+		_, _, err := p.RangeQuery("up")
+		assert.EqualError(t, err, fmt.Sprintf("step duration must be greater than 0, got %s", step))
+	}
+}
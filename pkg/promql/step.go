@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"time"
+)
+
+const (
+	// AutoStep is the step value that derives the step from the range and number of points to return
+	AutoStep = "auto"
+	// DefaultPoints is the number of points per series to aim for with an auto step when Points isn't set
+	DefaultPoints = 250
+	// MaxPoints is the maximum number of points per series prometheus will return for a range query
+	MaxPoints = 11000
+)
+
+// niceSteps are the step durations an auto step is rounded up to
+var niceSteps = []time.Duration{
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	15 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// Step returns the step that returns close to, but no more than, the requested number of points over the range.
+// Steps are rounded up to a sensible interval, e.g. 15s, 5m or 1h, and whole days beyond that.
+func Step(r time.Duration, points int) time.Duration {
+	if points <= 0 {
+		points = DefaultPoints
+	}
+	if points > MaxPoints {
+		points = MaxPoints
+	}
+	// A range of n steps returns n+1 points
+	raw := r / time.Duration(points)
+	if points > 1 {
+		raw = r / time.Duration(points-1)
+	}
+	for _, s := range niceSteps {
+		if s >= raw {
+			return s
+		}
+	}
+	day := 24 * time.Hour
+	return ((raw + day - 1) / day) * day
+}
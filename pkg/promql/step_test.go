@@ -0,0 +1,69 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep(t *testing.T) {
+	day := 24 * time.Hour
+	cases := []struct {
+		Range    time.Duration
+		Points   int
+		Expected time.Duration
+	}{
+		{Range: time.Hour, Points: 0, Expected: 15 * time.Second},
+		{Range: time.Hour, Points: 61, Expected: time.Minute},
+		{Range: time.Hour, Points: 60, Expected: 2 * time.Minute},
+		{Range: 30 * day, Points: 172, Expected: 6 * time.Hour},
+		{Range: 30 * day, Points: 20000, Expected: 5 * time.Minute},
+		{Range: 365 * day, Points: 100, Expected: 4 * day},
+		{Range: time.Second, Points: 100, Expected: time.Second},
+	}
+	for i, c := range cases {
+		step := Step(c.Range, c.Points)
+		assert.Equal(t, c.Expected, step, "Unexpected step for case %d", i)
+		assert.LessOrEqual(t, int(c.Range/step)+1, MaxPoints, "Too many points for case %d", i)
+	}
+}
+
+func TestGetRangeStep(t *testing.T) {
+	cases := []struct {
+		Step     string
+		Points   int
+		Expected time.Duration
+	}{
+		{Step: "", Expected: 10 * time.Minute},
+		{Step: AutoStep, Points: 50, Expected: 30 * time.Minute},
+		{Step: "1m", Expected: time.Minute},
+		{Step: "1d", Expected: 24 * time.Hour},
+	}
+	for i, c := range cases {
+		p := PromQL{Start: "1d", End: "now", Step: c.Step, Points: c.Points}
+		r, err := p.getRange()
+		if assert.NoError(t, err, "Unexpected error for case %d", i) {
+			assert.Equal(t, c.Expected, r.Step, "Unexpected step for case %d", i)
+		}
+	}
+	p := PromQL{Start: "1d", End: "now", Step: "fast"}
+	_, err := p.getRange()
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// RangeVar is the built-in variable holding the length of the range of a range query
+	RangeVar = "__range"
+	// IntervalVar is the built-in variable holding the step of a range query
+	IntervalVar = "__interval"
+	// RateIntervalVar is the built-in variable holding a range that's safe to use with rate() at the step of a range query
+	RateIntervalVar = "__rate_interval"
+	// DefaultScrapeInterval is the scrape interval $__rate_interval is derived from when ScrapeInterval isn't set
+	DefaultScrapeInterval = 15 * time.Second
+)
+
+// varPattern matches the $var and ${var} variables of a query template
+var varPattern = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// Expand substitutes the $var and ${var} variables of a query template with their values from Vars or the built-in variables.
+// Built-in variables are derived from the range and step of range queries, so they're only defined when a range start is set.
+// Values set in Vars take precedence over the built-in variables. Other undefined variables are left as they are, like grafana does,
+// since $ is also used by valid promql, e.g. the $1 capture group references of label_replace.
+func (p *PromQL) Expand(queryString string) (string, error) {
+	if !strings.Contains(queryString, "$") {
+		return queryString, nil
+	}
+	vars, err := p.templateVars()
+	if err != nil {
+		return queryString, err
+	}
+	undefined := make(map[string]bool)
+	expanded := varPattern.ReplaceAllStringFunc(queryString, func(m string) string {
+		name := strings.Trim(m, "${}")
+		v, ok := vars[name]
+		if !ok {
+			if strings.HasPrefix(name, "__") {
+				undefined[name] = true
+			}
+			return m
+		}
+		return v
+	})
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, "$"+name)
+		}
+		sort.Strings(names)
+		return queryString, fmt.Errorf("undefined variable %s, built-in variables are only defined for range queries", strings.Join(names, ", "))
+	}
+	return expanded, nil
+}
+
+// expandMatchers expands the variables of each of the series selectors passed to the series and labels endpoints
+func (p *PromQL) expandMatchers(matchers []string) ([]string, error) {
+	expanded := make([]string, len(matchers))
+	for i, m := range matchers {
+		var err error
+		if expanded[i], err = p.Expand(m); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// templateVars returns the values of the variables available to query templates
+func (p *PromQL) templateVars() (map[string]string, error) {
+	vars := make(map[string]string, len(p.Vars)+3)
+	if p.Start != "" {
+		r, err := p.getRange()
+		if err != nil {
+			return nil, err
+		}
+		// Relative times are each parsed against their own now, so round away the difference between them
+		vars[RangeVar] = formatDuration(r.End.Sub(r.Start).Round(time.Second))
+		vars[IntervalVar] = formatDuration(r.Step)
+		vars[RateIntervalVar] = formatDuration(rateInterval(r.Step, p.ScrapeInterval))
+	}
+	for k, v := range p.Vars {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// rateInterval returns the range to use with rate() at the provided step, following grafana's $__rate_interval.
+// It's at least four scrape intervals, so there are always enough samples in the range even if a scrape is missed,
+// and at least one scrape interval longer than the step, so no samples fall between the ranges of consecutive steps.
+func rateInterval(step time.Duration, scrapeInterval time.Duration) time.Duration {
+	if scrapeInterval <= 0 {
+		scrapeInterval = DefaultScrapeInterval
+	}
+	if step+scrapeInterval > 4*scrapeInterval {
+		return step + scrapeInterval
+	}
+	return 4 * scrapeInterval
+}
+
+// formatDuration formats a duration the way promql expects it in range selectors, e.g. 1h30m
+func formatDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// labelsAPI records the selectors passed to the labels endpoints
+type labelsAPI struct {
+	v1.API
+	matches []string
+}
+
+func (a *labelsAPI) LabelNames(ctx context.Context, matches []string, startTime time.Time, endTime time.Time) ([]string, v1.Warnings, error) {
+	a.matches = matches
+	return []string{"job"}, nil, nil
+}
+
+func (a *labelsAPI) LabelValues(ctx context.Context, label string, matches []string, startTime time.Time, endTime time.Time) (model.LabelValues, v1.Warnings, error) {
+	a.matches = matches
+	return model.LabelValues{"api"}, nil, nil
+}
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"cluster": "prod", "namespace": "kube-system"}
+	cases := []struct {
+		Query    string
+		Start    string
+		Step     string
+		Vars     map[string]string
+		Expected string
+		Error    string
+	}{
+		{Query: "up", Expected: "up"},
+		{Query: `up{cluster="$cluster"}`, Vars: vars, Expected: `up{cluster="prod"}`},
+		{Query: `up{namespace="${namespace}",cluster="$cluster"}`, Vars: vars, Expected: `up{namespace="kube-system",cluster="prod"}`},
+		{Query: `up{job=~"api$"}`, Expected: `up{job=~"api$"}`},
+		{
+			Query:    "sum(rate(http_requests_total[$__rate_interval])) / sum(increase(http_requests_total[$__range])) # $__interval",
+			Start:    "now-6h",
+			Step:     "1m",
+			Expected: "sum(rate(http_requests_total[1m15s])) / sum(increase(http_requests_total[6h])) # 1m",
+		},
+		{Query: "rate(x[$__rate_interval])", Start: "now-1h", Step: "15s", Expected: "rate(x[1m])"},
+		{Query: "rate(x[$__rate_interval])", Start: "now-1h", Step: "1m", Vars: map[string]string{RateIntervalVar: "5m"}, Expected: "rate(x[5m])"},
+		{Query: `up{cluster="$cluster",env="$env"}`, Vars: vars, Expected: `up{cluster="prod",env="$env"}`},
+		{Query: `label_replace(up, "host", "$1", "instance", "(.*):.*")`, Vars: vars, Expected: `label_replace(up, "host", "$1", "instance", "(.*):.*")`},
+		{Query: `label_replace(up{cluster="$cluster"}, "host", "${1}", "instance", "(.*):.*")`, Vars: vars, Expected: `label_replace(up{cluster="prod"}, "host", "${1}", "instance", "(.*):.*")`},
+		{Query: "rate(x[$__rate_interval])", Error: "undefined variable $__rate_interval, built-in variables are only defined for range queries"},
+	}
+	for _, c := range cases {
+		p := PromQL{Start: c.Start, End: "now", Step: c.Step, Vars: c.Vars, Location: time.UTC}
+		expanded, err := p.Expand(c.Query)
+		if c.Error != "" {
+			assert.EqualError(t, err, c.Error)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, c.Expected, expanded)
+	}
+}
+
+func TestExpandLabelMatchers(t *testing.T) {
+	api := &labelsAPI{}
+	p := PromQL{Client: api, Vars: map[string]string{"job": "api"}, Location: time.UTC}
+	_, _, err := p.LabelNamesQuery([]string{`up{job="$job"}`})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`up{job="api"}`}, api.matches)
+
+	_, _, err = p.LabelValuesQuery("instance", []string{`up{job="${job}"}`, "node_load1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`up{job="api"}`, "node_load1"}, api.matches)
+
+	_, _, err = p.LabelValuesQuery("instance", []string{`rate(up[$__range])`})
+	assert.Error(t, err)
+}
+
+func TestRateInterval(t *testing.T) {
+	assert.Equal(t, time.Minute, rateInterval(15*time.Second, 0))
+	assert.Equal(t, 75*time.Second, rateInterval(time.Minute, 0))
+	assert.Equal(t, 2*time.Minute, rateInterval(time.Minute, 30*time.Second))
+	assert.Equal(t, 6*time.Minute, rateInterval(5*time.Minute, time.Minute))
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// repl provides tab completion for the interactive promql shell
+package repl
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Keywords are the promql functions, aggregations and keywords offered as completions
+// alongside metric names
+var Keywords = []string{
+	"abs", "absent", "absent_over_time", "and", "avg", "avg_over_time", "bool", "bottomk",
+	"by", "ceil", "changes", "clamp", "clamp_max", "clamp_min", "count", "count_over_time",
+	"count_values", "day_of_month", "day_of_week", "day_of_year", "days_in_month", "delta",
+	"deriv", "exp", "floor", "group", "group_left", "group_right", "histogram_quantile",
+	"holt_winters", "hour", "idelta", "ignoring", "increase", "irate", "label_join",
+	"label_replace", "last_over_time", "ln", "log10", "log2", "max", "max_over_time", "min",
+	"min_over_time", "minute", "month", "offset", "on", "or", "predict_linear",
+	"present_over_time", "quantile", "quantile_over_time", "rate", "resets", "round",
+	"scalar", "sgn", "sort", "sort_desc", "sqrt", "stddev", "stddev_over_time", "stdvar",
+	"stdvar_over_time", "sum", "sum_over_time", "time", "timestamp", "topk", "unless",
+	"vector", "without", "year",
+}
+
+// Source provides the metric names, label names and label values used for completion
+type Source interface {
+	MetricNames() ([]string, error)
+	LabelNames(metric string) ([]string, error)
+	LabelValues(metric string, label string) ([]string, error)
+}
+
+// Completer completes promql expressions using candidates fetched from a Source.
+// Results are cached for the lifetime of the Completer.
+type Completer struct {
+	Source Source
+
+	mu      sync.Mutex
+	metrics []string
+	labels  map[string][]string
+	values  map[string][]string
+}
+
+// NewCompleter creates a Completer backed by the provided Source
+func NewCompleter(s Source) *Completer {
+	return &Completer{
+		Source: s,
+		labels: make(map[string][]string),
+		values: make(map[string][]string),
+	}
+}
+
+// Complete satisfies the liner.WordCompleter signature. It returns the line split around
+// the word being completed along with the candidates for that word.
+func (c *Completer) Complete(line string, pos int) (head string, completions []string, tail string) {
+	if pos > len(line) {
+		pos = len(line)
+	}
+	before, tail := line[:pos], line[pos:]
+
+	open := openBrace(before)
+	if open < 0 {
+		// Outside of a label selector we complete metric names and keywords
+		start := wordStart(before)
+		word := before[start:]
+		if word == "" {
+			return before, nil, tail
+		}
+		candidates := append([]string{}, Keywords...)
+		candidates = append(candidates, c.metricNames()...)
+		return before[:start], filter(candidates, word, ""), tail
+	}
+
+	metric := before[wordStart(before[:open]):open]
+	selector := before[open+1:]
+	if q := openQuote(selector); q >= 0 {
+		// Inside a quoted label value, find the label name preceding the matcher
+		matcher := strings.TrimRight(selector[:q], " =!~")
+		label := matcher[wordStart(matcher):]
+		word := selector[q+1:]
+		return before[:open+1+q+1], filter(c.labelValues(metric, label), word, selector[q:q+1]), tail
+	}
+	start := wordStart(selector)
+	word := selector[start:]
+	return before[:open+1+start], filter(c.labelNames(metric), word, ""), tail
+}
+
+func (c *Completer) metricNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metrics == nil {
+		m, err := c.Source.MetricNames()
+		if err != nil {
+			return nil
+		}
+		c.metrics = m
+	}
+	return c.metrics
+}
+
+func (c *Completer) labelNames(metric string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.labels[metric]; ok {
+		return l
+	}
+	l, err := c.Source.LabelNames(metric)
+	if err != nil {
+		return nil
+	}
+	c.labels[metric] = l
+	return l
+}
+
+func (c *Completer) labelValues(metric string, label string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := metric + "\xff" + label
+	if v, ok := c.values[key]; ok {
+		return v
+	}
+	v, err := c.Source.LabelValues(metric, label)
+	if err != nil {
+		return nil
+	}
+	c.values[key] = v
+	return v
+}
+
+// filter returns the sorted, de-duplicated candidates beginning with prefix, each with suffix appended
+func filter(candidates []string, prefix string, suffix string) []string {
+	seen := make(map[string]struct{})
+	var matches []string
+	for _, c := range candidates {
+		if !strings.HasPrefix(c, prefix) {
+			continue
+		}
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		matches = append(matches, c+suffix)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// isWordChar reports whether b can be part of a metric or label name
+func isWordChar(b byte) bool {
+	return b == '_' || b == ':' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// wordStart returns the index of the beginning of the trailing word in s
+func wordStart(s string) int {
+	i := len(s)
+	for i > 0 && isWordChar(s[i-1]) {
+		i--
+	}
+	return i
+}
+
+// openBrace returns the index of an unclosed '{' in s, or -1 if we're not in a label selector
+func openBrace(s string) int {
+	open := -1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case quote != 0:
+			if b == '\\' {
+				i++
+			} else if b == quote {
+				quote = 0
+			}
+		case b == '"' || b == '\'' || b == '`':
+			quote = b
+		case b == '{':
+			open = i
+		case b == '}':
+			open = -1
+		}
+	}
+	return open
+}
+
+// openQuote returns the index of an unclosed quote in s, or -1 if there isn't one
+func openQuote(s string) int {
+	open := -1
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case open >= 0:
+			if b == '\\' {
+				i++
+			} else if b == s[open] {
+				open = -1
+			}
+		case b == '"' || b == '\'' || b == '`':
+			open = i
+		}
+	}
+	return open
+}
@@ -0,0 +1,84 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSource struct{}
+
+func (s testSource) MetricNames() ([]string, error) {
+	return []string{"up", "http_requests_total", "http_request_duration_seconds_bucket"}, nil
+}
+
+func (s testSource) LabelNames(metric string) ([]string, error) {
+	if metric == "up" {
+		return []string{"__name__", "instance", "job"}, nil
+	}
+	return []string{"__name__", "code", "handler", "instance", "job"}, nil
+}
+
+func (s testSource) LabelValues(metric string, label string) ([]string, error) {
+	if label == "job" {
+		return []string{"node", "prometheus"}, nil
+	}
+	return []string{}, nil
+}
+
+func TestComplete(t *testing.T) {
+	cases := []struct {
+		Line        string
+		Head        string
+		Completions []string
+		Tail        string
+	}{
+		{
+			Line:        "http_req",
+			Head:        "",
+			Completions: []string{"http_request_duration_seconds_bucket", "http_requests_total"},
+		},
+		{
+			Line:        "sum(ra",
+			Head:        "sum(",
+			Completions: []string{"rate"},
+		},
+		{
+			Line:        "up{j",
+			Head:        "up{",
+			Completions: []string{"job"},
+		},
+		{
+			Line:        `up{instance="a", j`,
+			Head:        `up{instance="a", `,
+			Completions: []string{"job"},
+		},
+		{
+			Line:        `up{job="p`,
+			Head:        `up{job="`,
+			Completions: []string{`prometheus"`},
+		},
+		{
+			Line:        `up{job=~'`,
+			Head:        `up{job=~'`,
+			Completions: []string{"node'", "prometheus'"},
+		},
+		{
+			Line:        `rate(http_requests_total{c`,
+			Head:        `rate(http_requests_total{`,
+			Completions: []string{"code"},
+		},
+		{
+			Line:        `up{job="node"} + `,
+			Head:        `up{job="node"} + `,
+			Completions: nil,
+		},
+	}
+	c := NewCompleter(testSource{})
+	for i, tc := range cases {
+		head, completions, tail := c.Complete(tc.Line, len(tc.Line))
+		assert.Equal(t, tc.Head, head, "Unexpected head for case %d", i)
+		assert.Equal(t, tc.Completions, completions, "Unexpected completions for case %d", i)
+		assert.Equal(t, tc.Tail, tail, "Unexpected tail for case %d", i)
+	}
+}
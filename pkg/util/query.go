@@ -0,0 +1,71 @@
+/*
+ Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// StdinPath is the query file path that reads the query from stdin
+const StdinPath = "-"
+
+// ReadQuery reads a query from the file at path, or from stdin if path is "-", see CleanQuery for how it's parsed
+func ReadQuery(path string, stdin io.Reader) (string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == StdinPath {
+		b, err = io.ReadAll(stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read query, %v", err)
+	}
+	q := CleanQuery(string(b))
+	if q == "" {
+		return "", fmt.Errorf("no query found in %s", queryFileName(path))
+	}
+	return q, nil
+}
+
+// CleanQuery removes comment lines (starting with #) and blank lines from a multi-line query.
+// Comments after an expression on the same line are left for the server to ignore, since # can also appear in label values.
+func CleanQuery(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// queryFileName returns the name of the query file for error messages
+func queryFileName(path string) string {
+	if path == StdinPath {
+		return "stdin"
+	}
+	return path
+}
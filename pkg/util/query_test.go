@@ -0,0 +1,69 @@
+/*
+ Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanQuery(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{Input: "up", Expected: "up"},
+		{Input: "up\n", Expected: "up"},
+		{
+			Input:    "# requests per job\nsum(rate(http_requests_total[5m]))\n  by (job)\n\n",
+			Expected: "sum(rate(http_requests_total[5m]))\n  by (job)",
+		},
+		{
+			Input:    "sum(\n  # only 5xx responses\n  rate(http_requests_total{code=~\"5..\"}[5m])\n)\r\n",
+			Expected: "sum(\n  rate(http_requests_total{code=~\"5..\"}[5m])\n)",
+		},
+		{Input: "up{path=\"/#anchor\"} # trailing comment", Expected: "up{path=\"/#anchor\"} # trailing comment"},
+		{Input: "# only a comment\n", Expected: ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.Expected, CleanQuery(c.Input))
+	}
+}
+
+func TestReadQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.promql")
+	if err := os.WriteFile(path, []byte("# uptime\nup\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	q, err := ReadQuery(path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "up", q)
+
+	q, err = ReadQuery(StdinPath, strings.NewReader("sum(up)\n  by (job)\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sum(up)\n  by (job)", q)
+
+	_, err = ReadQuery(StdinPath, strings.NewReader("# nothing here\n"))
+	assert.EqualError(t, err, "no query found in stdin")
+
+	_, err = ReadQuery(filepath.Join(t.TempDir(), "missing.promql"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+ Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+var (
+	// relativeTimeRe matches times relative to now, e.g. now-2h
+	relativeTimeRe = regexp.MustCompile(`^now\s*([+-])\s*(\S+)$`)
+	// unixTimeRe matches unix timestamps in seconds or milliseconds, e.g. 1700000000 or 1700000000000
+	unixTimeRe = regexp.MustCompile(`^\d+(\.\d+)?$`)
+)
+
+// localLayouts are the date and time formats without a timezone, which are parsed in the configured location
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// timeOfDayLayouts are the time of day formats, which are parsed as a time on the current day in the configured location
+var timeOfDayLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// unixMillisThreshold is the smallest timestamp treated as milliseconds, anything lower is treated as seconds.
+// In seconds this is the year 5138, in milliseconds it's March 1973.
+const unixMillisThreshold = 1e11
+
+// ParseDuration parses a prometheus style duration (e.g. 1d, 1w, 1y or 1h30m), falling back to a go duration (e.g. 1.5h)
+func ParseDuration(s string) (time.Duration, error) {
+	if d, err := model.ParseDuration(s); err == nil {
+		return time.Duration(d), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("not a valid duration string: %q", s)
+	}
+	return d, nil
+}
+
+// ParseTime parses the time expressions accepted by the --time, --start and --end flags:
+//
+//	now, or a time relative to it e.g. now-2h
+//	a duration to look back from now e.g. 1d
+//	a unix timestamp in seconds or milliseconds e.g. 1700000000 or 1700000000000
+//	an RFC3339 date e.g. 2006-01-02T15:04:05Z
+//	a date and optional time without a timezone e.g. 2006-01-02 or 2006-01-02 15:04, in loc
+//	a time of day e.g. 15:04, on the current day in loc
+//
+// If loc is nil the local timezone is used.
+func ParseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now, nil
+	}
+	if m := relativeTimeRe.FindStringSubmatch(s); m != nil {
+		d, err := ParseDuration(m[2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %q, %v", s, err)
+		}
+		if m[1] == "-" {
+			d = -d
+		}
+		return now.Add(d), nil
+	}
+	if unixTimeRe.MatchString(s) {
+		// Parse whole timestamps as integers so milliseconds don't lose precision
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if i >= unixMillisThreshold {
+				return time.UnixMilli(i), nil
+			}
+			return time.Unix(i, 0), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse time %q, %v", s, err)
+		}
+		if f >= unixMillisThreshold {
+			f = f / 1e3
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range timeOfDayLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			y, mo, d := now.In(loc).Date()
+			return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q, expected now, now-<duration>, a duration e.g. 1d, a unix timestamp, an RFC3339 date, a date e.g. 2006-01-02 or a time of day e.g. 15:04", s)
+}
@@ -0,0 +1,71 @@
+/*
+ Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	cases := []struct {
+		Input    string
+		Location *time.Location
+		Expected time.Time
+		Error    bool
+	}{
+		{Input: "now", Expected: now},
+		{Input: "now-2h", Expected: now.Add(-2 * time.Hour)},
+		{Input: "now + 30m", Expected: now.Add(30 * time.Minute)},
+		{Input: "now-1.5h", Expected: now.Add(-90 * time.Minute)},
+		{Input: "1d", Expected: now.Add(-24 * time.Hour)},
+		{Input: "1w", Expected: now.Add(-7 * 24 * time.Hour)},
+		{Input: "1y", Expected: now.Add(-365 * 24 * time.Hour)},
+		{Input: "1h30m", Expected: now.Add(-90 * time.Minute)},
+		{Input: "1700000000", Expected: time.Unix(1700000000, 0)},
+		{Input: "1700000000.5", Expected: time.Unix(1700000000, 5e8)},
+		{Input: "1700000000123", Expected: time.Unix(1700000000, 123e6)},
+		{Input: "2023-11-14T10:00:00Z", Expected: time.Date(2023, 11, 14, 10, 0, 0, 0, time.UTC)},
+		{Input: "2023-11-14T10:00:00+02:00", Expected: time.Date(2023, 11, 14, 8, 0, 0, 0, time.UTC)},
+		{Input: "2023-11-14", Location: time.UTC, Expected: time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)},
+		{Input: "2023-11-14", Location: ny, Expected: time.Date(2023, 11, 14, 5, 0, 0, 0, time.UTC)},
+		{Input: "2023-11-14 10:30", Location: ny, Expected: time.Date(2023, 11, 14, 15, 30, 0, 0, time.UTC)},
+		{Input: "09:15", Location: time.UTC, Expected: time.Date(2023, 11, 14, 9, 15, 0, 0, time.UTC)},
+		// It's already the next day in UTC, but still the 14th in New York
+		{Input: "20:00:30", Location: ny, Expected: time.Date(2023, 11, 15, 1, 0, 30, 0, time.UTC)},
+		{Input: "now-2x", Error: true},
+		{Input: "yesterday", Error: true},
+		{Input: "", Error: true},
+	}
+	for i, c := range cases {
+		got, err := ParseTime(c.Input, now, c.Location)
+		if c.Error {
+			assert.Error(t, err, "Expected an error for case %d", i)
+			continue
+		}
+		if assert.NoError(t, err, "Unexpected error for case %d", i) {
+			assert.True(t, c.Expected.Equal(got), "Unexpected time for case %d: expected %s, got %s", i, c.Expected, got)
+		}
+	}
+}
@@ -58,6 +58,24 @@ func UniqLabels(result model.Value) (labels []model.LabelName, err error) {
 	return labels, err
 }
 
+// LabelSetNames returns the sorted, unique label names across a slice of label sets
+func LabelSetNames(sets []model.LabelSet) []model.LabelName {
+	labelKeys := make(map[model.LabelName]struct{})
+	for _, set := range sets {
+		for key := range set {
+			labelKeys[key] = struct{}{}
+		}
+	}
+	labels := make([]model.LabelName, 0, len(labelKeys))
+	for key := range labelKeys {
+		labels = append(labels, key)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return string(labels[i]) < string(labels[j])
+	})
+	return labels
+}
+
 // TermDimensions stores the width and height of the current terminal window
 // Used when setting the ascii graph size for range queries
 type TermDimensions struct {
@@ -87,5 +105,9 @@ func TerminalSize() (dimensions TermDimensions, err error) {
 	if err != nil {
 		return dimensions, err
 	}
+	// Some terminals (e.g. a pty without a window) report a size of 0 0
+	if dimensions.Height == 0 || dimensions.Width == 0 {
+		dimensions = TermDimensions{Height: 24, Width: 80}
+	}
 	return dimensions, nil
 }
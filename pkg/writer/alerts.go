@@ -0,0 +1,122 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// AlertsResult is the list of active alerts from an alerts query
+// It satisfies the InstantWriter interface
+type AlertsResult []v1.Alert
+
+// Filter returns the alerts in the provided state, sorted by alert name. An empty state matches all alerts.
+func (r *AlertsResult) Filter(state string) AlertsResult {
+	alerts := AlertsResult{}
+	for _, a := range *r {
+		if state != "" && string(a.State) != state {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Labels[model.AlertNameLabel] < alerts[j].Labels[model.AlertNameLabel]
+	})
+	return alerts
+}
+
+// alertRow returns the columns written out for a single alert
+func alertRow(a v1.Alert) []string {
+	labels := a.Labels.Clone()
+	delete(labels, model.AlertNameLabel)
+	activeAt := ""
+	if !a.ActiveAt.IsZero() {
+		activeAt = a.ActiveAt.Format(time.RFC3339)
+	}
+	return []string{
+		string(a.Labels[model.AlertNameLabel]),
+		string(a.State),
+		activeAt,
+		a.Value,
+		labels.String(),
+	}
+}
+
+// Table returns the alerts as a tab separated table
+func (r *AlertsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titles := []string{"ALERTNAME", "STATE", "ACTIVE_SINCE", "VALUE", "LABELS"}
+		titleRow := strings.Join(titles, "\t")
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, a := range *r {
+		row := strings.Join(alertRow(a), "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the alerts as json
+func (r *AlertsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the alerts as a csv
+func (r *AlertsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"alertname", "state", "active_since", "value", "labels"}
+		rows = append(rows, titleRow)
+	}
+	for _, a := range *r {
+		rows = append(rows, alertRow(a))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
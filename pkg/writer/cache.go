@@ -0,0 +1,99 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nalbury/promql-cli/pkg/cache"
+)
+
+// CacheStatsResult is a summary of the entries in the result cache
+// It satisfies the InstantWriter interface
+type CacheStatsResult []cache.Stat
+
+// cacheStatRow returns the columns of a cache stat
+func cacheStatRow(s cache.Stat) []string {
+	return []string{
+		s.Kind,
+		strconv.Itoa(s.Entries),
+		strconv.FormatInt(s.Bytes, 10),
+		strconv.Itoa(s.Expired),
+		s.Oldest.Format(time.RFC3339),
+		s.Newest.Format(time.RFC3339),
+	}
+}
+
+// Table returns the cache stats as a tab separated table
+func (r *CacheStatsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titleRow := "KIND\tENTRIES\tBYTES\tEXPIRED\tOLDEST\tNEWEST"
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, s := range *r {
+		if _, err := fmt.Fprintln(w, strings.Join(cacheStatRow(s), "\t")); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the cache stats as json
+func (r *CacheStatsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the cache stats as a csv
+func (r *CacheStatsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"kind", "entries", "bytes", "expired", "oldest", "newest"}
+		rows = append(rows, titleRow)
+	}
+	for _, s := range *r {
+		rows = append(rows, cacheStatRow(s))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// CardinalityResult is the result of a TSDB stats query
+// It satisfies the InstantWriter interface
+type CardinalityResult struct {
+	v1.TSDBResult
+}
+
+// statSection is a titled list of TSDB stats
+type statSection struct {
+	Key         string
+	Title       string
+	NameColumn  string
+	ValueColumn string
+	Stats       []v1.Stat
+}
+
+// sections returns each list of stats in the result along with how to label it
+func (r *CardinalityResult) sections() []statSection {
+	return []statSection{
+		{"series_count_by_metric_name", "Top metrics by series count", "METRIC", "SERIES", r.SeriesCountByMetricName},
+		{"label_value_count_by_label_name", "Top label names by value count", "LABEL", "VALUES", r.LabelValueCountByLabelName},
+		{"memory_in_bytes_by_label_name", "Top label names by memory usage", "LABEL", "BYTES", r.MemoryInBytesByLabelName},
+		{"series_count_by_label_value_pair", "Top label value pairs by series count", "PAIR", "SERIES", r.SeriesCountByLabelValuePair},
+	}
+}
+
+// Limit returns the result with each list of stats truncated to at most n entries
+func (r *CardinalityResult) Limit(n int) CardinalityResult {
+	limit := func(stats []v1.Stat) []v1.Stat {
+		if n > 0 && len(stats) > n {
+			return stats[:n]
+		}
+		return stats
+	}
+	l := *r
+	l.SeriesCountByMetricName = limit(l.SeriesCountByMetricName)
+	l.LabelValueCountByLabelName = limit(l.LabelValueCountByLabelName)
+	l.MemoryInBytesByLabelName = limit(l.MemoryInBytesByLabelName)
+	l.SeriesCountByLabelValuePair = limit(l.SeriesCountByLabelValuePair)
+	return l
+}
+
+// headStatsRow returns the head stats columns, with min and max time formatted as dates
+func (r *CardinalityResult) headStatsRow() []string {
+	h := r.HeadStats
+	return []string{
+		strconv.Itoa(h.NumSeries),
+		strconv.Itoa(h.NumLabelPairs),
+		strconv.Itoa(h.ChunkCount),
+		time.UnixMilli(int64(h.MinTime)).UTC().Format(time.RFC3339),
+		time.UnixMilli(int64(h.MaxTime)).UTC().Format(time.RFC3339),
+	}
+}
+
+// Table returns the head stats followed by a tab separated table for each list of stats
+func (r *CardinalityResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titles := []string{"HEAD_SERIES", "LABEL_PAIRS", "CHUNKS", "MIN_TIME", "MAX_TIME"}
+		if _, err := fmt.Fprintln(w, strings.Join(titles, "\t")); err != nil {
+			return buf, err
+		}
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(r.headStatsRow(), "\t")); err != nil {
+		return buf, err
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	for _, s := range r.sections() {
+		// Each section gets its own tabwriter so column widths are aligned per section
+		w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+		if _, err := fmt.Fprintln(w); err != nil {
+			return buf, err
+		}
+		if !noHeaders {
+			if _, err := fmt.Fprintf(w, "# %s\n%s\t%s\n", s.Title, s.NameColumn, s.ValueColumn); err != nil {
+				return buf, err
+			}
+		}
+		for _, stat := range s.Stats {
+			if _, err := fmt.Fprintf(w, "%s\t%d\n", stat.Name, stat.Value); err != nil {
+				return buf, err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// Json returns the TSDB stats as json
+func (r *CardinalityResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r.TSDBResult)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the TSDB stats as a csv with a row per stat, prefixed by the list it belongs to
+func (r *CardinalityResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		rows = append(rows, []string{"section", "name", "value"})
+	}
+	head := r.headStatsRow()
+	for i, name := range []string{"num_series", "num_label_pairs", "chunk_count", "min_time", "max_time"} {
+		rows = append(rows, []string{"head_stats", name, head[i]})
+	}
+	for _, s := range r.sections() {
+		for _, stat := range s.Stats {
+			rows = append(rows, []string{s.Key, stat.Name, strconv.FormatUint(stat.Value, 10)})
+		}
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
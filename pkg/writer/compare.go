@@ -0,0 +1,248 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nalbury/promql-cli/pkg/util"
+	"github.com/prometheus/common/model"
+)
+
+// Evaluation is the result of an instant query evaluated at a single time
+type Evaluation struct {
+	// Name identifies the evaluation in column headers, e.g. the --time value it was evaluated at
+	Name   string
+	Vector model.Vector
+}
+
+// CompareResult is the result of an instant query evaluated at multiple times.
+// Each series gets a value for every evaluation, plus the absolute and percentage change
+// from every later evaluation to the first one.
+// It satisfies the InstantWriter interface
+type CompareResult []Evaluation
+
+// compareRow is a single series along with its value in each evaluation, nil if the series is missing from it
+type compareRow struct {
+	Metric model.Metric
+	Values []*model.SampleValue
+}
+
+// compareJson is the json representation of a compareRow
+type compareJson struct {
+	Metric        model.Metric                 `json:"metric"`
+	Values        map[string]model.SampleValue `json:"values"`
+	Deltas        map[string]model.SampleValue `json:"deltas"`
+	DeltaPercents map[string]model.SampleValue `json:"delta_percents"`
+}
+
+// rows merges the series of every evaluation by their labels, in the order they first appear
+func (r *CompareResult) rows() []compareRow {
+	var rows []compareRow
+	index := make(map[model.Fingerprint]int)
+	for i, e := range *r {
+		for _, s := range e.Vector {
+			fp := s.Metric.Fingerprint()
+			n, ok := index[fp]
+			if !ok {
+				n = len(rows)
+				index[fp] = n
+				rows = append(rows, compareRow{Metric: s.Metric, Values: make([]*model.SampleValue, len(*r))})
+			}
+			v := s.Value
+			rows[n].Values[i] = &v
+		}
+	}
+	return rows
+}
+
+// labels returns the label names of every series in every evaluation
+func (r *CompareResult) labels() ([]model.LabelName, error) {
+	var all model.Vector
+	for _, e := range *r {
+		all = append(all, e.Vector...)
+	}
+	return util.UniqLabels(all)
+}
+
+// delta returns the absolute and percentage change from then to now, ok is false if either value is missing.
+// Any change from 0 is an infinite percentage.
+func delta(now *model.SampleValue, then *model.SampleValue) (abs float64, pct float64, ok bool) {
+	if now == nil || then == nil {
+		return 0, 0, false
+	}
+	abs = float64(*now - *then)
+	if abs == 0 {
+		return 0, 0, true
+	}
+	return abs, abs / float64(*then) * 100, true
+}
+
+// formatPercent formats a percentage with an explicit sign, e.g. +12.50%
+func formatPercent(pct float64) string {
+	return fmt.Sprintf("%+.2f%%", pct)
+}
+
+// cells returns the value, delta and percentage delta columns of a row
+func (r *CompareResult) cells(row compareRow) []string {
+	var cells []string
+	for _, v := range row.Values {
+		if v == nil {
+			cells = append(cells, "")
+			continue
+		}
+		cells = append(cells, v.String())
+	}
+	for i := 1; i < len(row.Values); i++ {
+		abs, pct, ok := delta(row.Values[0], row.Values[i])
+		if !ok {
+			cells = append(cells, "", "")
+			continue
+		}
+		cells = append(cells, strconv.FormatFloat(abs, 'f', -1, 64), formatPercent(pct))
+	}
+	return cells
+}
+
+// titles returns the value, delta and percentage delta column titles
+func (r *CompareResult) titles() []string {
+	var titles []string
+	for _, e := range *r {
+		titles = append(titles, "value@"+e.Name)
+	}
+	for _, e := range (*r)[1:] {
+		titles = append(titles, "delta@"+e.Name, "delta_%@"+e.Name)
+	}
+	return titles
+}
+
+// Table returns the merged series as a tab separated table
+func (r *CompareResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	if len(*r) == 0 {
+		return buf, nil
+	}
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	labels, err := r.labels()
+	if err != nil {
+		return buf, err
+	}
+	if !noHeaders {
+		var titles []string
+		for _, k := range labels {
+			titles = append(titles, strings.ToUpper(string(k)))
+		}
+		for _, t := range r.titles() {
+			// Only upper case the column type, the evaluation names are left as the user provided them
+			parts := strings.SplitN(t, "@", 2)
+			titles = append(titles, strings.ToUpper(parts[0])+"@"+parts[1])
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(titles, "\t")); err != nil {
+			return buf, err
+		}
+	}
+	for _, row := range r.rows() {
+		data := make([]string, len(labels))
+		for i, key := range labels {
+			data[i] = string(row.Metric[key])
+		}
+		data = append(data, r.cells(row)...)
+		if _, err := fmt.Fprintln(w, strings.Join(data, "\t")); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the merged series as json, with values and deltas keyed by evaluation name
+func (r *CompareResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	out := []compareJson{}
+	for _, row := range r.rows() {
+		j := compareJson{
+			Metric:        row.Metric,
+			Values:        make(map[string]model.SampleValue),
+			Deltas:        make(map[string]model.SampleValue),
+			DeltaPercents: make(map[string]model.SampleValue),
+		}
+		for i, v := range row.Values {
+			name := (*r)[i].Name
+			if v != nil {
+				j.Values[name] = *v
+			}
+			if i == 0 {
+				continue
+			}
+			if abs, pct, ok := delta(row.Values[0], v); ok {
+				j.Deltas[name] = model.SampleValue(abs)
+				j.DeltaPercents[name] = model.SampleValue(pct)
+			}
+		}
+		out = append(out, j)
+	}
+	o, err := json.Marshal(out)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the merged series as a csv
+func (r *CompareResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	if len(*r) == 0 {
+		return buf, nil
+	}
+	w := csv.NewWriter(&buf)
+	labels, err := r.labels()
+	if err != nil {
+		return buf, err
+	}
+	if !noHeaders {
+		var titleRow []string
+		for _, k := range labels {
+			titleRow = append(titleRow, string(k))
+		}
+		titleRow = append(titleRow, r.titles()...)
+		rows = append(rows, titleRow)
+	}
+	for _, row := range r.rows() {
+		data := make([]string, len(labels))
+		for i, key := range labels {
+			data[i] = string(row.Metric[key])
+		}
+		rows = append(rows, append(data, r.cells(row)...))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nalbury/promql-cli/pkg/promql"
+	"github.com/prometheus/common/model"
+)
+
+// CostResult is the estimated cost of a query, broken down by vector selector
+// It satisfies the InstantWriter interface
+type CostResult struct {
+	promql.Cost
+}
+
+// costJson is the json representation of a CostResult, with durations formatted like promql durations
+type costJson struct {
+	Start          time.Time          `json:"start"`
+	End            time.Time          `json:"end"`
+	Step           string             `json:"step"`
+	ScrapeInterval string             `json:"scrape_interval"`
+	Selectors      []costSelectorJson `json:"selectors"`
+	Samples        int64              `json:"samples"`
+}
+
+// costSelectorJson is the json representation of a promql.SelectorCost
+type costSelectorJson struct {
+	Selector    string `json:"selector"`
+	Series      int    `json:"series"`
+	Range       string `json:"range"`
+	Evaluations int64  `json:"evaluations"`
+	Samples     int64  `json:"samples"`
+}
+
+// costDuration formats a duration like a promql duration, empty if it's zero
+func costDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return model.Duration(d).String()
+}
+
+// costRow returns the columns of a selector's cost
+func costRow(s promql.SelectorCost) []string {
+	return []string{
+		s.Selector,
+		strconv.Itoa(s.Series),
+		costDuration(s.Range),
+		strconv.FormatInt(s.Evaluations, 10),
+		strconv.FormatInt(s.Samples, 10),
+	}
+}
+
+// Table returns a row per selector as a tab separated table, followed by the total samples
+func (r *CostResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titleRow := "SELECTOR\tSERIES\tRANGE\tEVALUATIONS\tSAMPLES"
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, s := range r.Selectors {
+		if _, err := fmt.Fprintln(w, strings.Join(costRow(s), "\t")); err != nil {
+			return buf, err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "TOTAL\t\t\t\t%d\n", r.Samples); err != nil {
+		return buf, err
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the estimate as json
+func (r *CostResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	j := costJson{
+		Start:          r.Start,
+		End:            r.End,
+		Step:           costDuration(r.Step),
+		ScrapeInterval: costDuration(r.ScrapeInterval),
+		Selectors:      []costSelectorJson{},
+		Samples:        r.Samples,
+	}
+	for _, s := range r.Selectors {
+		j.Selectors = append(j.Selectors, costSelectorJson{
+			Selector:    s.Selector,
+			Series:      s.Series,
+			Range:       costDuration(s.Range),
+			Evaluations: s.Evaluations,
+			Samples:     s.Samples,
+		})
+	}
+	o, err := json.Marshal(j)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns a row per selector as a csv
+func (r *CostResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"selector", "series", "range", "evaluations", "samples"}
+		rows = append(rows, titleRow)
+	}
+	for _, s := range r.Selectors {
+		rows = append(rows, costRow(s))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
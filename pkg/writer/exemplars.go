@@ -0,0 +1,210 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// TraceIDLabels are the exemplar label names commonly used to hold a trace ID
+var TraceIDLabels = []model.LabelName{"trace_id", "traceID", "traceId", "TraceID"}
+
+// exemplarMarker is the character used to mark exemplars below a graph
+const exemplarMarker = "◆"
+
+// ExemplarsResult is the result of an exemplars query
+// It satisfies the InstantWriter interface
+type ExemplarsResult []v1.ExemplarQueryResult
+
+// exemplarRow is a single exemplar along with the series it belongs to
+type exemplarRow struct {
+	Series    model.LabelSet
+	TraceID   string
+	Value     model.SampleValue
+	Timestamp model.Time
+	Labels    model.LabelSet
+}
+
+// traceID splits the trace ID out of the labels of an exemplar, returning it along with the remaining labels
+func traceID(labels model.LabelSet) (string, model.LabelSet) {
+	rest := labels.Clone()
+	for _, name := range TraceIDLabels {
+		if id, ok := rest[name]; ok {
+			delete(rest, name)
+			return string(id), rest
+		}
+	}
+	return "", rest
+}
+
+// rows flattens the exemplars of each series into a row per exemplar
+func (r *ExemplarsResult) rows() []exemplarRow {
+	var rows []exemplarRow
+	for _, s := range *r {
+		for _, e := range s.Exemplars {
+			id, labels := traceID(e.Labels)
+			rows = append(rows, exemplarRow{
+				Series:    s.SeriesLabels,
+				TraceID:   id,
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+				Labels:    labels,
+			})
+		}
+	}
+	return rows
+}
+
+// Table returns the exemplars as a tab separated table
+func (r *ExemplarsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titleRow := "SERIES\tTRACE_ID\tVALUE\tTIMESTAMP\tLABELS"
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, e := range r.rows() {
+		row := strings.Join([]string{
+			e.Series.String(),
+			e.TraceID,
+			e.Value.String(),
+			e.Timestamp.Time().Format(time.RFC3339),
+			e.Labels.String(),
+		}, "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the exemplars as json
+func (r *ExemplarsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the exemplars as a csv
+func (r *ExemplarsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"series", "trace_id", "value", "timestamp", "labels"}
+		rows = append(rows, titleRow)
+	}
+	for _, e := range r.rows() {
+		row := []string{
+			e.Series.String(),
+			e.TraceID,
+			e.Value.String(),
+			e.Timestamp.Time().Format(time.RFC3339),
+			e.Labels.String(),
+		}
+		rows = append(rows, row)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// For returns the exemplars of series whose labels include all of the labels of the provided metric.
+// Graphed queries are often aggregations of the series exemplars are attached to,
+// e.g. histogram_quantile(0.9, sum by (le, job) (rate(http_request_duration_seconds_bucket[5m]))),
+// so an exact match would rarely find anything.
+func (r *ExemplarsResult) For(metric model.Metric) []v1.Exemplar {
+	var exemplars []v1.Exemplar
+	for _, s := range *r {
+		match := true
+		for name, value := range metric {
+			if name == model.MetricNameLabel {
+				continue
+			}
+			if s.SeriesLabels[name] != value {
+				match = false
+				break
+			}
+		}
+		if match {
+			exemplars = append(exemplars, s.Exemplars...)
+		}
+	}
+	return exemplars
+}
+
+// exemplarAnnotation returns a row of markers aligned with the x axis of graph, one for each exemplar
+// between start and end, followed by the trace ID, time and value of each marked exemplar.
+// width is the number of data columns in the graph.
+func exemplarAnnotation(graph string, width int, start model.Time, end model.Time, exemplars []v1.Exemplar) string {
+	if len(exemplars) == 0 || width < 1 {
+		return ""
+	}
+	// The data columns start right after the y axis, which follows the labels on each line of the graph
+	firstLine := []rune(strings.SplitN(graph, "\n", 2)[0])
+	offset := 0
+	for i, c := range firstLine {
+		if c == '┤' || c == '┼' {
+			offset = i + 1
+			break
+		}
+	}
+	markers := []rune(strings.Repeat(" ", offset+width))
+	var legend []string
+	for _, e := range exemplars {
+		if e.Timestamp.Before(start) || e.Timestamp.After(end) {
+			continue
+		}
+		x := 0
+		if end > start {
+			x = int(math.Round(float64(e.Timestamp-start) / float64(end-start) * float64(width-1)))
+		}
+		markers[offset+x] = []rune(exemplarMarker)[0]
+		id, _ := traceID(e.Labels)
+		if id == "" {
+			id = e.Labels.String()
+		}
+		legend = append(legend, fmt.Sprintf("%s %s  %s  %s", exemplarMarker, e.Timestamp.Time().Format(time.Stamp), e.Value, id))
+	}
+	if len(legend) == 0 {
+		return ""
+	}
+	return strings.TrimRight(string(markers), " ") + "\n" + strings.Join(legend, "\n")
+}
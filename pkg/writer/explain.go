@@ -0,0 +1,267 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// ExplainResult is the parsed expression tree of a query.
+// It satisfies the InstantWriter interface
+type ExplainResult struct {
+	Expr parser.Expr
+}
+
+// explainNode is a single node of the expression tree, with the fields that apply to its kind
+type explainNode struct {
+	Kind      string           `json:"kind"`
+	Expr      string           `json:"expr"`
+	ValueType string           `json:"value_type"`
+	Op        string           `json:"op,omitempty"`
+	Function  string           `json:"function,omitempty"`
+	Grouping  []string         `json:"grouping,omitempty"`
+	Without   bool             `json:"without,omitempty"`
+	Bool      bool             `json:"bool,omitempty"`
+	Matching  *explainMatching `json:"matching,omitempty"`
+	Metric    string           `json:"metric,omitempty"`
+	Matchers  []explainMatcher `json:"matchers,omitempty"`
+	Range     string           `json:"range,omitempty"`
+	Step      string           `json:"step,omitempty"`
+	Offset    string           `json:"offset,omitempty"`
+	At        string           `json:"at,omitempty"`
+	Value     string           `json:"value,omitempty"`
+	Children  []explainNode    `json:"children,omitempty"`
+	// detail is the description of the node on its line of the tree
+	detail string
+}
+
+// explainMatching is how the series of both sides of a binary operator are matched
+type explainMatching struct {
+	Cardinality string   `json:"cardinality"`
+	On          bool     `json:"on"`
+	Labels      []string `json:"labels"`
+	Include     []string `json:"include,omitempty"`
+}
+
+// explainMatcher is a single label matcher of a vector selector
+type explainMatcher struct {
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// explain converts an expression and its children into explainNodes
+func explain(expr parser.Expr) explainNode {
+	n := explainNode{Expr: expr.String(), ValueType: string(expr.Type())}
+	switch e := expr.(type) {
+	case *parser.AggregateExpr:
+		n.Kind = "aggregation"
+		n.Op = e.Op.String()
+		n.Grouping = e.Grouping
+		n.Without = e.Without
+		n.detail = n.Op
+		if len(e.Grouping) > 0 || e.Without {
+			clause := "by"
+			if e.Without {
+				clause = "without"
+			}
+			n.detail += fmt.Sprintf(" %s (%s)", clause, strings.Join(e.Grouping, ", "))
+		}
+		if e.Param != nil {
+			n.Children = append(n.Children, explain(e.Param))
+		}
+		n.Children = append(n.Children, explain(e.Expr))
+	case *parser.BinaryExpr:
+		n.Kind = "binary_operator"
+		n.Op = e.Op.String()
+		n.Bool = e.ReturnBool
+		n.detail = n.Op
+		if e.ReturnBool {
+			n.detail += " bool"
+		}
+		if m := e.VectorMatching; m != nil {
+			n.Matching = &explainMatching{Cardinality: m.Card.String(), On: m.On, Labels: append([]string{}, m.MatchingLabels...), Include: m.Include}
+			n.detail += ", " + m.Card.String()
+			switch {
+			case m.On:
+				n.detail += fmt.Sprintf(" on (%s)", strings.Join(m.MatchingLabels, ", "))
+			case len(m.MatchingLabels) > 0:
+				n.detail += fmt.Sprintf(" ignoring (%s)", strings.Join(m.MatchingLabels, ", "))
+			default:
+				n.detail += " on all labels"
+			}
+			switch m.Card {
+			case parser.CardManyToOne:
+				n.detail += fmt.Sprintf(" group_left (%s)", strings.Join(m.Include, ", "))
+			case parser.CardOneToMany:
+				n.detail += fmt.Sprintf(" group_right (%s)", strings.Join(m.Include, ", "))
+			}
+		}
+		n.Children = append(n.Children, explain(e.LHS), explain(e.RHS))
+	case *parser.Call:
+		n.Kind = "function_call"
+		n.Function = e.Func.Name
+		n.detail = e.Func.Name + "()"
+		for _, arg := range e.Args {
+			n.Children = append(n.Children, explain(arg))
+		}
+	case *parser.MatrixSelector:
+		n.Kind = "range_selector"
+		n.Range = model.Duration(e.Range).String()
+		n.detail = fmt.Sprintf("[%s]", n.Range)
+		n.Children = append(n.Children, explain(e.VectorSelector))
+	case *parser.SubqueryExpr:
+		n.Kind = "subquery"
+		n.Range = model.Duration(e.Range).String()
+		if e.Step != 0 {
+			n.Step = model.Duration(e.Step).String()
+		}
+		n.Offset = explainOffset(e.OriginalOffset)
+		n.At = explainAt(e.Timestamp, e.StartOrEnd)
+		n.detail = strings.TrimPrefix(e.String(), e.Expr.String())
+		n.Children = append(n.Children, explain(e.Expr))
+	case *parser.VectorSelector:
+		n.Kind = "vector_selector"
+		n.Metric = e.Name
+		for _, m := range e.LabelMatchers {
+			// The metric name is also a matcher, which is already covered by Metric
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual && m.Value == e.Name {
+				continue
+			}
+			n.Matchers = append(n.Matchers, explainMatcher{Label: m.Name, Type: m.Type.String(), Value: m.Value})
+		}
+		n.Offset = explainOffset(e.OriginalOffset)
+		n.At = explainAt(e.Timestamp, e.StartOrEnd)
+		n.detail = e.String()
+	case *parser.NumberLiteral:
+		n.Kind = "number"
+		n.Value = strconv.FormatFloat(e.Val, 'g', -1, 64)
+		n.detail = n.Value
+	case *parser.StringLiteral:
+		n.Kind = "string"
+		n.Value = e.Val
+		n.detail = strconv.Quote(e.Val)
+	case *parser.ParenExpr:
+		n.Kind = "parentheses"
+		n.detail = "( )"
+		n.Children = append(n.Children, explain(e.Expr))
+	case *parser.UnaryExpr:
+		n.Kind = "unary_operator"
+		n.Op = e.Op.String()
+		n.detail = n.Op
+		n.Children = append(n.Children, explain(e.Expr))
+	case *parser.StepInvariantExpr:
+		return explain(e.Expr)
+	default:
+		n.Kind = fmt.Sprintf("%T", expr)
+		n.detail = expr.String()
+	}
+	return n
+}
+
+// explainOffset formats the offset modifier of a selector or subquery, empty if it has none
+func explainOffset(offset time.Duration) string {
+	switch {
+	case offset > 0:
+		return model.Duration(offset).String()
+	case offset < 0:
+		return "-" + model.Duration(-offset).String()
+	}
+	return ""
+}
+
+// explainAt formats the @ modifier of a selector or subquery, empty if it has none
+func explainAt(ts *int64, startOrEnd parser.ItemType) string {
+	switch {
+	case ts != nil:
+		return time.UnixMilli(*ts).UTC().Format(time.RFC3339Nano)
+	case startOrEnd == parser.START:
+		return "start()"
+	case startOrEnd == parser.END:
+		return "end()"
+	}
+	return ""
+}
+
+// line returns the line of the tree for a node
+func (n explainNode) line() string {
+	return fmt.Sprintf("%s: %s → %s", n.Kind, n.detail, n.ValueType)
+}
+
+// writeTree writes a node and its children as an indented tree
+func (n explainNode) writeTree(buf *bytes.Buffer, prefix string, childPrefix string) {
+	buf.WriteString(prefix + n.line() + "\n")
+	for i, c := range n.Children {
+		if i == len(n.Children)-1 {
+			c.writeTree(buf, childPrefix+"└── ", childPrefix+"    ")
+			continue
+		}
+		c.writeTree(buf, childPrefix+"├── ", childPrefix+"│   ")
+	}
+}
+
+// Table returns the expression as an indented tree with a node per line
+func (r *ExplainResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	explain(r.Expr).writeTree(&buf, "", "")
+	return buf, nil
+}
+
+// Json returns the expression tree as json, with each node's children nested under it
+func (r *ExplainResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(explain(r.Expr))
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns a row per node of the expression tree, in depth first order along with its depth
+func (r *ExplainResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		rows = append(rows, []string{"depth", "kind", "detail", "value_type"})
+	}
+	var walk func(n explainNode, depth int)
+	walk = func(n explainNode, depth int) {
+		rows = append(rows, []string{strconv.Itoa(depth), n.Kind, n.detail, n.ValueType})
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	walk(explain(r.Expr), 0)
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// Rule types used when filtering a RulesResult
+const (
+	AlertingRuleType  = "alerting"
+	RecordingRuleType = "recording"
+)
+
+// RulesResult is the list of rule groups from a rules query
+// It satisfies the InstantWriter interface
+type RulesResult []v1.RuleGroup
+
+// Filter returns the rule groups matching the provided group name, containing only rules of the provided type.
+// Empty values match all groups and rules, and groups left without any rules are dropped.
+func (r *RulesResult) Filter(group string, ruleType string) RulesResult {
+	groups := RulesResult{}
+	for _, g := range *r {
+		if group != "" && g.Name != group {
+			continue
+		}
+		var rules v1.Rules
+		for _, rule := range g.Rules {
+			if ruleType == "" || ruleTypeOf(rule) == ruleType {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		g.Rules = rules
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// ruleTypeOf returns the type of a rule from a rule group
+func ruleTypeOf(rule interface{}) string {
+	switch rule.(type) {
+	case v1.AlertingRule:
+		return AlertingRuleType
+	case v1.RecordingRule:
+		return RecordingRuleType
+	default:
+		return ""
+	}
+}
+
+// ruleRows returns the columns written out for each rule in the result
+func (r *RulesResult) ruleRows() [][]string {
+	var rows [][]string
+	for _, g := range *r {
+		for _, rule := range g.Rules {
+			var (
+				name, state, health, lastError string
+				lastEvaluation                 time.Time
+				evaluationTime                 float64
+			)
+			switch v := rule.(type) {
+			case v1.AlertingRule:
+				name, state, health, lastError = v.Name, v.State, string(v.Health), v.LastError
+				lastEvaluation, evaluationTime = v.LastEvaluation, v.EvaluationTime
+			case v1.RecordingRule:
+				name, health, lastError = v.Name, string(v.Health), v.LastError
+				lastEvaluation, evaluationTime = v.LastEvaluation, v.EvaluationTime
+			default:
+				continue
+			}
+			lastEval := ""
+			if !lastEvaluation.IsZero() {
+				lastEval = lastEvaluation.Format(time.RFC3339)
+			}
+			rows = append(rows, []string{
+				g.Name,
+				name,
+				ruleTypeOf(rule),
+				health,
+				state,
+				lastEval,
+				seconds(evaluationTime),
+				lastError,
+			})
+		}
+	}
+	return rows
+}
+
+// Table returns the rules as a tab separated table, one row per rule
+func (r *RulesResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titles := []string{"GROUP", "NAME", "TYPE", "HEALTH", "STATE", "LAST_EVALUATION", "DURATION", "LAST_ERROR"}
+		titleRow := strings.Join(titles, "\t")
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, data := range r.ruleRows() {
+		row := strings.Join(data, "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the rule groups as json
+func (r *RulesResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the rules as a csv, one row per rule
+func (r *RulesResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"group", "name", "type", "health", "state", "last_evaluation", "duration", "last_error"}
+		rows = append(rows, titleRow)
+	}
+	rows = append(rows, r.ruleRows()...)
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Status report sections
+const (
+	BuildSection   = "build"
+	RuntimeSection = "runtime"
+	FlagsSection   = "flags"
+	ConfigSection  = "config"
+)
+
+// StatusSections are the sections of the status report, in the order they are printed
+var StatusSections = []string{BuildSection, RuntimeSection, FlagsSection, ConfigSection}
+
+// RelevantFlags are the server flags most likely to explain a difference in query results between servers
+var RelevantFlags = []string{
+	"enable-feature",
+	"query.lookback-delta",
+	"query.max-concurrency",
+	"query.max-samples",
+	"query.timeout",
+	"storage.tsdb.path",
+	"storage.tsdb.retention.size",
+	"storage.tsdb.retention.time",
+	"web.enable-admin-api",
+	"web.enable-lifecycle",
+	"web.external-url",
+}
+
+// StatusResult is a report of the build info, runtime info, flags and config of the server.
+// Sections left nil, or missing from Sections when it is set, are omitted from the report.
+// It satisfies the InstantWriter interface
+type StatusResult struct {
+	Build   *v1.BuildinfoResult
+	Runtime *v1.RuntimeinfoResult
+	Flags   v1.FlagsResult
+	Config  *v1.ConfigResult
+	// Now is the time uptime is calculated from
+	Now time.Time
+	// Sections limits the report to the named sections
+	Sections []string
+}
+
+// Include returns true if the named section should be part of the report
+func (r *StatusResult) Include(section string) bool {
+	if len(r.Sections) == 0 {
+		return true
+	}
+	for _, s := range r.Sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// statusSection is a titled list of settings in the status report
+type statusSection struct {
+	Key      string
+	Title    string
+	Settings []Setting
+}
+
+// promConfig is the subset of the prometheus config file summarized in the status report
+type promConfig struct {
+	Global struct {
+		ScrapeInterval     string            `yaml:"scrape_interval"`
+		ScrapeTimeout      string            `yaml:"scrape_timeout"`
+		EvaluationInterval string            `yaml:"evaluation_interval"`
+		ExternalLabels     map[string]string `yaml:"external_labels"`
+	} `yaml:"global"`
+	RuleFiles     []string      `yaml:"rule_files"`
+	ScrapeConfigs []interface{} `yaml:"scrape_configs"`
+	RemoteWrite   []interface{} `yaml:"remote_write"`
+	RemoteRead    []interface{} `yaml:"remote_read"`
+}
+
+// sections returns the settings of each section present in the report
+func (r *StatusResult) sections() ([]statusSection, error) {
+	var sections []statusSection
+	if b := r.Build; b != nil && r.Include(BuildSection) {
+		sections = append(sections, statusSection{BuildSection, "Build", []Setting{
+			{Name: "version", Value: b.Version},
+			{Name: "revision", Value: b.Revision},
+			{Name: "branch", Value: b.Branch},
+			{Name: "build_user", Value: b.BuildUser},
+			{Name: "build_date", Value: b.BuildDate},
+			{Name: "go_version", Value: b.GoVersion},
+		}})
+	}
+	if rt := r.Runtime; rt != nil && r.Include(RuntimeSection) {
+		sections = append(sections, statusSection{RuntimeSection, "Runtime", []Setting{
+			{Name: "start_time", Value: rt.StartTime.Format(time.RFC3339)},
+			{Name: "uptime", Value: r.Now.Sub(rt.StartTime).Round(time.Second).String()},
+			{Name: "storage_retention", Value: rt.StorageRetention},
+			{Name: "wal_corruptions", Value: strconv.Itoa(rt.CorruptionCount)},
+			{Name: "goroutines", Value: strconv.Itoa(rt.GoroutineCount)},
+			{Name: "gomaxprocs", Value: strconv.Itoa(rt.GOMAXPROCS)},
+			{Name: "gogc", Value: rt.GOGC},
+			{Name: "cwd", Value: rt.CWD},
+		}})
+	}
+	if r.Flags != nil && r.Include(FlagsSection) {
+		var flags []Setting
+		for _, name := range RelevantFlags {
+			if v, ok := r.Flags[name]; ok {
+				flags = append(flags, Setting{Name: name, Value: v})
+			}
+		}
+		sections = append(sections, statusSection{FlagsSection, "Flags", flags})
+	}
+	if r.Config != nil && r.Include(ConfigSection) {
+		var c promConfig
+		if err := yaml.Unmarshal([]byte(r.Config.YAML), &c); err != nil {
+			return sections, fmt.Errorf("error parsing server config: %v", err)
+		}
+		var settings []Setting
+		// The reload status lives in the runtime info, so it's only known if that was queried too
+		if rt := r.Runtime; rt != nil {
+			settings = append(settings,
+				Setting{Name: "reload_success", Value: strconv.FormatBool(rt.ReloadConfigSuccess)},
+				Setting{Name: "last_reload", Value: rt.LastConfigTime.Format(time.RFC3339)},
+			)
+		}
+		externalLabels := make(model.LabelSet, len(c.Global.ExternalLabels))
+		for k, v := range c.Global.ExternalLabels {
+			externalLabels[model.LabelName(k)] = model.LabelValue(v)
+		}
+		settings = append(settings,
+			Setting{Name: "scrape_interval", Value: c.Global.ScrapeInterval},
+			Setting{Name: "scrape_timeout", Value: c.Global.ScrapeTimeout},
+			Setting{Name: "evaluation_interval", Value: c.Global.EvaluationInterval},
+			Setting{Name: "external_labels", Value: externalLabels.String()},
+			Setting{Name: "scrape_configs", Value: strconv.Itoa(len(c.ScrapeConfigs))},
+			Setting{Name: "rule_files", Value: strconv.Itoa(len(c.RuleFiles))},
+			Setting{Name: "remote_write", Value: strconv.Itoa(len(c.RemoteWrite))},
+			Setting{Name: "remote_read", Value: strconv.Itoa(len(c.RemoteRead))},
+		)
+		sections = append(sections, statusSection{ConfigSection, "Config", settings})
+	}
+	return sections, nil
+}
+
+// Table returns a tab separated table for each section of the report
+func (r *StatusResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	sections, err := r.sections()
+	if err != nil {
+		return buf, err
+	}
+	for i, s := range sections {
+		// Each section gets its own tabwriter so column widths are aligned per section
+		w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return buf, err
+			}
+		}
+		if !noHeaders {
+			if _, err := fmt.Fprintf(w, "# %s\nNAME\tVALUE\n", s.Title); err != nil {
+				return buf, err
+			}
+		}
+		for _, setting := range s.Settings {
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", setting.Name, setting.Value); err != nil {
+				return buf, err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// statusJSON is the json layout of the report
+type statusJSON struct {
+	Build   *v1.BuildinfoResult   `json:"build,omitempty"`
+	Runtime *v1.RuntimeinfoResult `json:"runtime,omitempty"`
+	Flags   v1.FlagsResult        `json:"flags,omitempty"`
+	Config  *statusConfigJSON     `json:"config,omitempty"`
+}
+
+// statusConfigJSON is the config of the server, along with its reload status from the runtime info when that was queried too
+type statusConfigJSON struct {
+	v1.ConfigResult
+	ReloadSuccess *bool      `json:"reload_success,omitempty"`
+	LastReload    *time.Time `json:"last_reload,omitempty"`
+}
+
+// Json returns the raw build info, runtime info, flags and config of the server as json
+func (r *StatusResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	var j statusJSON
+	if r.Include(BuildSection) {
+		j.Build = r.Build
+	}
+	if r.Include(RuntimeSection) {
+		j.Runtime = r.Runtime
+	}
+	if r.Include(FlagsSection) {
+		j.Flags = r.Flags
+	}
+	if r.Config != nil && r.Include(ConfigSection) {
+		j.Config = &statusConfigJSON{ConfigResult: *r.Config}
+		if rt := r.Runtime; rt != nil {
+			j.Config.ReloadSuccess = &rt.ReloadConfigSuccess
+			j.Config.LastReload = &rt.LastConfigTime
+		}
+	}
+	o, err := json.Marshal(j)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the report as a csv with a row per setting, prefixed by the section it belongs to
+func (r *StatusResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	sections, err := r.sections()
+	if err != nil {
+		return buf, err
+	}
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		rows = append(rows, []string{"section", "name", "value"})
+	}
+	for _, s := range sections {
+		for _, setting := range s.Settings {
+			rows = append(rows, []string{s.Key, setting.Name, setting.Value})
+		}
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright © 2020 Nick Albury nickalbury@gmail.com
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package writer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// TargetsResult is the list of active scrape targets from a targets query
+// It satisfies the InstantWriter interface
+type TargetsResult []v1.ActiveTarget
+
+// Filter returns the targets matching the provided job, health and scrape pool.
+// Empty values match all targets.
+func (r *TargetsResult) Filter(job string, health string, pool string) TargetsResult {
+	targets := TargetsResult{}
+	for _, t := range *r {
+		if job != "" && string(t.Labels["job"]) != job {
+			continue
+		}
+		if health != "" && string(t.Health) != health {
+			continue
+		}
+		if pool != "" && t.ScrapePool != pool {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].ScrapePool != targets[j].ScrapePool {
+			return targets[i].ScrapePool < targets[j].ScrapePool
+		}
+		return targets[i].ScrapeURL < targets[j].ScrapeURL
+	})
+	return targets
+}
+
+// targetRow returns the columns written out for a single target
+func targetRow(t v1.ActiveTarget) []string {
+	lastScrape := ""
+	if !t.LastScrape.IsZero() {
+		lastScrape = t.LastScrape.Format(time.RFC3339)
+	}
+	return []string{
+		t.ScrapePool,
+		t.ScrapeURL,
+		string(t.Health),
+		lastScrape,
+		seconds(t.LastScrapeDuration),
+		t.LastError,
+		t.Labels.String(),
+	}
+}
+
+// Table returns the targets as a tab separated table
+func (r *TargetsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titles := []string{"POOL", "URL", "HEALTH", "LAST_SCRAPE", "DURATION", "LAST_ERROR", "LABELS"}
+		titleRow := strings.Join(titles, "\t")
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, t := range *r {
+		row := strings.Join(targetRow(t), "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the targets as json
+func (r *TargetsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the targets as a csv
+func (r *TargetsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"pool", "url", "health", "last_scrape", "duration", "last_error", "labels"}
+		rows = append(rows, titleRow)
+	}
+	for _, t := range *r {
+		rows = append(rows, targetRow(t))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// seconds formats a duration in seconds, as returned by the prometheus API, as a human readable duration
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Microsecond).String()
+}
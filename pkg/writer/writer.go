@@ -22,6 +22,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -57,6 +58,8 @@ type InstantWriter interface {
 // Satisfies the RangeWriter interface
 type RangeResult struct {
 	model.Matrix
+	// Exemplars are marked below the graph of each series they belong to
+	Exemplars ExemplarsResult
 }
 
 // Graph returns an ascii graph using https://github.com/guptarohit/asciigraph
@@ -74,6 +77,10 @@ func (r *RangeResult) Graph(dim util.TermDimensions) (bytes.Buffer, error) {
 			borderLength int
 		)
 
+		// Skip series without any samples, there's nothing to plot
+		if len(m.Values) == 0 {
+			continue
+		}
 		for _, v := range m.Values {
 			data = append(data, float64(v.Value))
 		}
@@ -125,6 +132,20 @@ func (r *RangeResult) Graph(dim util.TermDimensions) (bytes.Buffer, error) {
 		if _, err := fmt.Fprintf(&buf, "%s\n", graph); err != nil {
 			return buf, err
 		}
+		if len(r.Exemplars) > 0 {
+			// The graph interpolates our samples to fill the requested width, the last point ends the line in the previous column
+			points := dim.Width - 8
+			if points <= 0 {
+				points = len(data)
+			}
+			exemplars := r.Exemplars.For(m.Metric)
+			annotation := exemplarAnnotation(graph, points-1, m.Values[0].Timestamp, m.Values[len(m.Values)-1].Timestamp, exemplars)
+			if annotation != "" {
+				if _, err := fmt.Fprintf(&buf, "%s\n", annotation); err != nil {
+					return buf, err
+				}
+			}
+		}
 	}
 	return buf, nil
 }
@@ -180,33 +201,29 @@ func (r *RangeResult) Csv(noHeaders bool) (bytes.Buffer, error) {
 	return buf, nil
 }
 
-// WriteRange writes out the results of the query to an
-// output buffer and prints it to stdout
-func WriteRange(r RangeWriter, format string, noHeaders bool) error {
-	var (
-		buf bytes.Buffer
-		err error
-	)
+// RenderRange writes out the results of the query to an
+// output buffer in the desired format
+func RenderRange(r RangeWriter, format string, noHeaders bool) (bytes.Buffer, error) {
 	switch format {
 	case "json":
-		buf, err = r.Json()
-		if err != nil {
-			return err
-		}
+		return r.Json()
 	case "csv":
-		buf, err = r.Csv(noHeaders)
-		if err != nil {
-			return err
-		}
+		return r.Csv(noHeaders)
 	default:
 		dim, err := util.TerminalSize()
 		if err != nil {
-			return err
-		}
-		buf, err = r.Graph(dim)
-		if err != nil {
-			return err
+			return bytes.Buffer{}, err
 		}
+		return r.Graph(dim)
+	}
+}
+
+// WriteRange writes out the results of the query to an
+// output buffer and prints it to stdout
+func WriteRange(r RangeWriter, format string, noHeaders bool) error {
+	buf, err := RenderRange(r, format, noHeaders)
+	if err != nil {
+		return err
 	}
 	fmt.Println(buf.String())
 	return nil
@@ -307,6 +324,99 @@ func (r *InstantResult) Csv(noHeaders bool) (bytes.Buffer, error) {
 	return buf, nil
 }
 
+// ScalarResult is wrapper of the prometheus model.Scalar type returned from instant queries
+// Satisfies the InstantWriter interface
+type ScalarResult struct {
+	*model.Scalar
+}
+
+// Table returns the response from a scalar query as a tab separated table
+func (r *ScalarResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	return valueTable(r.Value.String(), r.Timestamp, noHeaders)
+}
+
+// Json returns the response from a scalar query as json
+func (r *ScalarResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r.Scalar)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the response from a scalar query as a csv
+func (r *ScalarResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	return valueCsv(r.Value.String(), r.Timestamp, noHeaders)
+}
+
+// StringResult is wrapper of the prometheus model.String type returned from instant queries
+// Satisfies the InstantWriter interface
+type StringResult struct {
+	*model.String
+}
+
+// Table returns the response from a string query as a tab separated table
+func (r *StringResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	return valueTable(r.Value, r.Timestamp, noHeaders)
+}
+
+// Json returns the response from a string query as json
+func (r *StringResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r.String)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the response from a string query as a csv
+func (r *StringResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	return valueCsv(r.Value, r.Timestamp, noHeaders)
+}
+
+// valueTable returns a single value and timestamp as a tab separated table
+func valueTable(value string, ts model.Time, noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titleRow := "VALUE\tTIMESTAMP"
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	row := value + "\t" + ts.Time().Format(time.RFC3339)
+	if _, err := fmt.Fprintln(w, row); err != nil {
+		return buf, err
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// valueCsv returns a single value and timestamp as a csv
+func valueCsv(value string, ts model.Time, noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"value", "timestamp"}
+		rows = append(rows, titleRow)
+	}
+	rows = append(rows, []string{value, ts.Time().Format(time.RFC3339)})
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
 // MetricsResult is the list of metrics names from a metadata query result
 // It satisfies the InstantWriter interface as it's
 // a point in time (e.g. what metrics are currently queryable)
@@ -441,6 +551,105 @@ func (r *LabelsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
 	return buf, nil
 }
 
+// LabelNamesResult is the list of label names from a label names query
+// It satisfies the InstantWriter interface
+type LabelNamesResult []string
+
+// Table returns the label names as a single column table
+func (r *LabelNamesResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	return columnTable("LABELS", *r, noHeaders)
+}
+
+// Json returns the label names as json
+func (r *LabelNamesResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the label names as a single column csv
+func (r *LabelNamesResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	return columnCsv("labels", *r, noHeaders)
+}
+
+// LabelValuesResult is the list of values from a label values query
+// It satisfies the InstantWriter interface
+type LabelValuesResult model.LabelValues
+
+// strings returns the label values as a slice of strings
+func (r *LabelValuesResult) strings() []string {
+	values := make([]string, 0, len(*r))
+	for _, v := range *r {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+// Table returns the label values as a single column table
+func (r *LabelValuesResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	return columnTable("VALUES", r.strings(), noHeaders)
+}
+
+// Json returns the label values as json
+func (r *LabelValuesResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r.strings())
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the label values as a single column csv
+func (r *LabelValuesResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	return columnCsv("values", r.strings(), noHeaders)
+}
+
+// columnTable returns a list of strings as a single column table
+func columnTable(title string, column []string, noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		if _, err := fmt.Fprintln(w, title); err != nil {
+			return buf, err
+		}
+	}
+	for _, row := range column {
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// columnCsv returns a list of strings as a single column csv
+func columnCsv(title string, column []string, noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		rows = append(rows, []string{title})
+	}
+	for _, v := range column {
+		rows = append(rows, []string{v})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
 // MetaResult is the result of our metadata query
 // It satisfies the InstantWriter interface
 type MetaResult map[string][]v1.Metadata
@@ -525,9 +734,81 @@ func (r *MetaResult) Table(noHeaders bool) (bytes.Buffer, error) {
 	return buf, nil
 }
 
-// SeriesResult currently doesn't write anything itself but helps create other result types like metrics from the series api
+// SeriesResult is the list of label sets returned from the series api
+// It satisfies the InstantWriter interface, and helps create other result types like metrics
 type SeriesResult []model.LabelSet
 
+// Table returns the series as a tab separated table with a column per label
+func (r *SeriesResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	labels := util.LabelSetNames(*r)
+	if !noHeaders {
+		var titles []string
+		for _, k := range labels {
+			titles = append(titles, strings.ToUpper(string(k)))
+		}
+		titleRow := strings.Join(titles, "\t")
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, s := range *r {
+		data := make([]string, len(labels))
+		for i, key := range labels {
+			data[i] = string(s[key])
+		}
+		row := strings.Join(data, "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the series as json
+func (r *SeriesResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the series as a csv with a column per label
+func (r *SeriesResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	labels := util.LabelSetNames(*r)
+	if !noHeaders {
+		var titleRow []string
+		for _, k := range labels {
+			titleRow = append(titleRow, string(k))
+		}
+		rows = append(rows, titleRow)
+	}
+	for _, s := range *r {
+		row := make([]string, len(labels))
+		for i, key := range labels {
+			row[i] = string(s[key])
+		}
+		rows = append(rows, row)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
 // Metrics creates a MetricsResult from a SeriesResult
 func (r *SeriesResult) Metrics() MetricsResult {
 	u := make(map[string]struct{})
@@ -542,29 +823,161 @@ func (r *SeriesResult) Metrics() MetricsResult {
 	return m
 }
 
-// WriteInstant writes out the results of the query to an
-// output buffer and prints it to stdout
-func WriteInstant(i InstantWriter, format string, noHeaders bool) error {
+// Setting is a single named configuration value
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SettingsResult is a list of configuration values, e.g. the current settings of the interactive shell
+// It satisfies the InstantWriter interface
+type SettingsResult []Setting
+
+// Table returns the settings as a tab separated table
+func (r *SettingsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titleRow := "SETTING\tVALUE"
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, s := range *r {
+		row := s.Name + "\t" + s.Value
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the settings as json
+func (r *SettingsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the settings as a csv
+func (r *SettingsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
+	var (
+		buf  bytes.Buffer
+		rows [][]string
+	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"setting", "value"}
+		rows = append(rows, titleRow)
+	}
+	for _, s := range *r {
+		row := []string{s.Name, s.Value}
+		rows = append(rows, row)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Context describes a named server context from the config file
+type Context struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	Current bool   `json:"current"`
+}
+
+// ContextsResult is the list of contexts defined in the config file
+// It satisfies the InstantWriter interface
+type ContextsResult []Context
+
+// Table returns the contexts as a tab separated table, marking the current context with a *
+func (r *ContextsResult) Table(noHeaders bool) (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	const padding = 4
+	w := tabwriter.NewWriter(&buf, 0, 0, padding, ' ', 0)
+	if !noHeaders {
+		titles := []string{"CURRENT", "NAME", "HOST"}
+		titleRow := strings.Join(titles, "\t")
+		if _, err := fmt.Fprintln(w, titleRow); err != nil {
+			return buf, err
+		}
+	}
+	for _, c := range *r {
+		current := ""
+		if c.Current {
+			current = "*"
+		}
+		row := strings.Join([]string{current, c.Name, c.Host}, "\t")
+		if _, err := fmt.Fprintln(w, row); err != nil {
+			return buf, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// Json returns the contexts as json
+func (r *ContextsResult) Json() (bytes.Buffer, error) {
+	var buf bytes.Buffer
+	o, err := json.Marshal(r)
+	if err != nil {
+		return buf, err
+	}
+	buf.Write(o)
+	return buf, nil
+}
+
+// Csv returns the contexts as a csv
+func (r *ContextsResult) Csv(noHeaders bool) (bytes.Buffer, error) {
 	var (
-		buf bytes.Buffer
-		err error
+		buf  bytes.Buffer
+		rows [][]string
 	)
+	w := csv.NewWriter(&buf)
+	if !noHeaders {
+		titleRow := []string{"current", "name", "host"}
+		rows = append(rows, titleRow)
+	}
+	for _, c := range *r {
+		row := []string{strconv.FormatBool(c.Current), c.Name, c.Host}
+		rows = append(rows, row)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return buf, err
+	}
+	return buf, nil
+}
+
+// RenderInstant writes out the results of the query to an
+// output buffer in the desired format
+func RenderInstant(i InstantWriter, format string, noHeaders bool) (bytes.Buffer, error) {
 	switch format {
 	case "json":
-		buf, err = i.Json()
-		if err != nil {
-			return err
-		}
+		return i.Json()
 	case "csv":
-		buf, err = i.Csv(noHeaders)
-		if err != nil {
-			return err
-		}
+		return i.Csv(noHeaders)
 	default:
-		buf, err = i.Table(noHeaders)
-		if err != nil {
-			return err
-		}
+		return i.Table(noHeaders)
+	}
+}
+
+// WriteInstant writes out the results of the query to an
+// output buffer and prints it to stdout
+func WriteInstant(i InstantWriter, format string, noHeaders bool) error {
+	buf, err := RenderInstant(i, format, noHeaders)
+	if err != nil {
+		return err
 	}
 	fmt.Println(buf.String())
 	return nil
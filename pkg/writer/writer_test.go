@@ -5,9 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nalbury/promql-cli/pkg/promql"
 	"github.com/nalbury/promql-cli/pkg/util"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +21,7 @@ func TestRangeGraph(t *testing.T) {
 	}{
 		{
 			Result: RangeResult{
-				model.Matrix{
+				Matrix: model.Matrix{
 					{
 						Metric: map[model.LabelName]model.LabelValue{
 							"__name__": "my_metric",
@@ -44,6 +46,19 @@ func TestRangeGraph(t *testing.T) {
 				now.Time().Format(time.Stamp),
 			),
 		},
+		{
+			Result: RangeResult{
+				Matrix: model.Matrix{
+					{
+						Metric: map[model.LabelName]model.LabelValue{
+							"__name__": "my_metric",
+						},
+						Values: []model.SamplePair{},
+					},
+				},
+			},
+			Expected: "",
+		},
 	}
 	for i, c := range cases {
 		dim := util.TermDimensions{
@@ -64,7 +79,7 @@ func TestJson(t *testing.T) {
 	}{
 		{
 			Result: &RangeResult{
-				model.Matrix{
+				Matrix: model.Matrix{
 					{
 						Metric: map[model.LabelName]model.LabelValue{
 							"__name__": "my_metric",
@@ -139,6 +154,106 @@ func TestJson(t *testing.T) {
 			},
 			Expected: "{\"my_metric\":[{\"type\":\"counter\",\"help\":\"The best metric you've ever recorded\",\"unit\":\"\"}]}",
 		},
+		{
+			Result: &SettingsResult{
+				{Name: "host", Value: "http://localhost:9090"},
+			},
+			Expected: "[{\"name\":\"host\",\"value\":\"http://localhost:9090\"}]",
+		},
+		{
+			Result: &ContextsResult{
+				{Name: "prod", Host: "https://prod.example.com", Current: true},
+				{Name: "staging", Host: "https://staging.example.com"},
+			},
+			Expected: "[{\"name\":\"prod\",\"host\":\"https://prod.example.com\",\"current\":true},{\"name\":\"staging\",\"host\":\"https://staging.example.com\",\"current\":false}]",
+		},
+		{
+			Result: &ScalarResult{
+				&model.Scalar{
+					Value:     1.0,
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("[%s,\"1\"]", now.String()),
+		},
+		{
+			Result: &StringResult{
+				&model.String{
+					Value:     "hello",
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("[%s,\"hello\"]", now.String()),
+		},
+		{
+			Result:   &LabelNamesResult{"instance", "job"},
+			Expected: "[\"instance\",\"job\"]",
+		},
+		{
+			Result:   &LabelValuesResult{"node", "prometheus"},
+			Expected: "[\"node\",\"prometheus\"]",
+		},
+		{
+			Result: &SeriesResult{
+				{"__name__": "up", "job": "node"},
+				{"__name__": "up", "job": "prometheus", "env": "prod"},
+			},
+			Expected: "[{\"__name__\":\"up\",\"job\":\"node\"},{\"__name__\":\"up\",\"env\":\"prod\",\"job\":\"prometheus\"}]",
+		},
+		{
+			Result: &CardinalityResult{
+				TSDBResult: v1.TSDBResult{
+					HeadStats:               v1.TSDBHeadStats{NumSeries: 508},
+					SeriesCountByMetricName: []v1.Stat{{Name: "up", Value: 20}},
+				},
+			},
+			Expected: `{"headStats":{"numSeries":508,"numLabelPairs":0,"chunkCount":0,"minTime":0,"maxTime":0},"seriesCountByMetricName":[{"name":"up","value":20}],"labelValueCountByLabelName":null,"memoryInBytesByLabelName":null,"seriesCountByLabelValuePair":null}`,
+		},
+		{
+			Result: &StatusResult{
+				Build:    &v1.BuildinfoResult{Version: "2.40.1"},
+				Flags:    v1.FlagsResult{"query.timeout": "2m"},
+				Sections: []string{FlagsSection},
+			},
+			Expected: `{"flags":{"query.timeout":"2m"}}`,
+		},
+		{
+			Result: &CompareResult{
+				{Name: "now", Vector: model.Vector{{Metric: model.Metric{"job": "api"}, Value: 3, Timestamp: now}}},
+				{Name: "now-1d", Vector: model.Vector{{Metric: model.Metric{"job": "api"}, Value: 2, Timestamp: now}}},
+			},
+			Expected: `[{"metric":{"job":"api"},"values":{"now":"3","now-1d":"2"},"deltas":{"now-1d":"1"},"delta_percents":{"now-1d":"50"}}]`,
+		},
+		{
+			Result: &ExplainResult{Expr: mustParseExpr(`rate(up{job="api"}[5m] @ 1700000000)`)},
+			Expected: `{"kind":"function_call","expr":"rate(up{job=\"api\"}[5m] @ 1700000000.000)","value_type":"vector","function":"rate","children":[` +
+				`{"kind":"range_selector","expr":"up{job=\"api\"}[5m] @ 1700000000.000","value_type":"matrix","range":"5m","children":[` +
+				`{"kind":"vector_selector","expr":"up{job=\"api\"} @ 1700000000.000","value_type":"vector","metric":"up","matchers":[{"label":"job","type":"=","value":"api"}],"at":"2023-11-14T22:13:20Z"}]}]}`,
+		},
+		{
+			Result: &CostResult{promql.Cost{
+				Start:          time.Date(2023, 11, 14, 11, 0, 0, 0, time.UTC),
+				End:            time.Date(2023, 11, 14, 12, 0, 0, 0, time.UTC),
+				Step:           time.Minute,
+				ScrapeInterval: 15 * time.Second,
+				Selectors:      []promql.SelectorCost{{Selector: "up", Series: 4, Evaluations: 61, Samples: 244}},
+				Samples:        244,
+			}},
+			Expected: `{"start":"2023-11-14T11:00:00Z","end":"2023-11-14T12:00:00Z","step":"1m","scrape_interval":"15s",` +
+				`"selectors":[{"selector":"up","series":4,"range":"","evaluations":61,"samples":244}],"samples":244}`,
+		},
+		{
+			Result: &StatusResult{
+				Runtime: &v1.RuntimeinfoResult{
+					StartTime:           time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC),
+					ReloadConfigSuccess: true,
+					LastConfigTime:      time.Date(2022, 11, 20, 10, 0, 5, 0, time.UTC),
+				},
+				Config:   &v1.ConfigResult{YAML: "global:\n  scrape_interval: 30s\n"},
+				Sections: []string{ConfigSection},
+			},
+			Expected: `{"config":{"yaml":"global:\n  scrape_interval: 30s\n","reload_success":true,"last_reload":"2022-11-20T10:00:05Z"}}`,
+		},
 	}
 	for i, c := range cases {
 		buf, err := c.Result.Json()
@@ -155,7 +270,7 @@ func TestCsv(t *testing.T) {
 	}{
 		{
 			Result: &RangeResult{
-				model.Matrix{
+				Matrix: model.Matrix{
 					{
 						Metric: map[model.LabelName]model.LabelValue{
 							"__name__": "my_metric",
@@ -230,6 +345,190 @@ func TestCsv(t *testing.T) {
 			},
 			Expected: "metric,type,help,unit\nmy_metric,counter,The best metric you've ever recorded,\n",
 		},
+		{
+			Result: &SettingsResult{
+				{Name: "host", Value: "http://localhost:9090"},
+			},
+			Expected: "setting,value\nhost,http://localhost:9090\n",
+		},
+		{
+			Result: &ContextsResult{
+				{Name: "prod", Host: "https://prod.example.com", Current: true},
+				{Name: "staging", Host: "https://staging.example.com"},
+			},
+			Expected: "current,name,host\ntrue,prod,https://prod.example.com\nfalse,staging,https://staging.example.com\n",
+		},
+		{
+			Result: &ScalarResult{
+				&model.Scalar{
+					Value:     1.0,
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("value,timestamp\n1,%s\n", now.Time().Format(time.RFC3339)),
+		},
+		{
+			Result: &StringResult{
+				&model.String{
+					Value:     "hello",
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("value,timestamp\nhello,%s\n", now.Time().Format(time.RFC3339)),
+		},
+		{
+			Result: &TargetsResult{
+				{
+					Labels:             model.LabelSet{"job": "node"},
+					ScrapePool:         "node",
+					ScrapeURL:          "http://localhost:9100/metrics",
+					LastError:          "connection refused",
+					LastScrape:         now.Time(),
+					LastScrapeDuration: 0.0012,
+					Health:             v1.HealthBad,
+				},
+			},
+			Expected: fmt.Sprintf(
+				"pool,url,health,last_scrape,duration,last_error,labels\nnode,http://localhost:9100/metrics,down,%s,1.2ms,connection refused,\"{job=\"\"node\"\"}\"\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &AlertsResult{
+				{
+					ActiveAt: now.Time(),
+					Labels:   model.LabelSet{"alertname": "TargetDown", "job": "node"},
+					State:    v1.AlertStateFiring,
+					Value:    "1e+00",
+				},
+			},
+			Expected: fmt.Sprintf(
+				"alertname,state,active_since,value,labels\nTargetDown,firing,%s,1e+00,\"{job=\"\"node\"\"}\"\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &RulesResult{
+				{
+					Name: "node",
+					Rules: v1.Rules{
+						v1.AlertingRule{
+							Name:           "TargetDown",
+							Health:         v1.RuleHealthGood,
+							EvaluationTime: 0.000312,
+							LastEvaluation: now.Time(),
+							State:          "firing",
+						},
+						v1.RecordingRule{
+							Name:           "job:up:sum",
+							Health:         v1.RuleHealthBad,
+							LastError:      "bad query",
+							EvaluationTime: 0.0021,
+							LastEvaluation: now.Time(),
+						},
+					},
+				},
+			},
+			Expected: fmt.Sprintf(
+				"group,name,type,health,state,last_evaluation,duration,last_error\nnode,TargetDown,alerting,ok,firing,%s,312µs,\nnode,job:up:sum,recording,err,,%s,2.1ms,bad query\n",
+				now.Time().Format(time.RFC3339),
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result:   &LabelNamesResult{"instance", "job"},
+			Expected: "labels\ninstance\njob\n",
+		},
+		{
+			Result:   &LabelValuesResult{"node", "prometheus"},
+			Expected: "values\nnode\nprometheus\n",
+		},
+		{
+			Result: &SeriesResult{
+				{"__name__": "up", "job": "node"},
+				{"__name__": "up", "job": "prometheus", "env": "prod"},
+			},
+			Expected: "__name__,env,job\nup,,node\nup,prod,prometheus\n",
+		},
+		{
+			Result: &CardinalityResult{
+				TSDBResult: v1.TSDBResult{
+					HeadStats:               v1.TSDBHeadStats{NumSeries: 508, NumLabelPairs: 1234, ChunkCount: 937, MinTime: 1700000000000, MaxTime: 1700007200000},
+					SeriesCountByMetricName: []v1.Stat{{Name: "up", Value: 20}},
+				},
+			},
+			Expected: "section,name,value\nhead_stats,num_series,508\nhead_stats,num_label_pairs,1234\nhead_stats,chunk_count,937\nhead_stats,min_time,2023-11-14T22:13:20Z\nhead_stats,max_time,2023-11-15T00:13:20Z\nseries_count_by_metric_name,up,20\n",
+		},
+		{
+			Result: &StatusResult{
+				Runtime: &v1.RuntimeinfoResult{
+					StartTime:           time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC),
+					ReloadConfigSuccess: true,
+					LastConfigTime:      time.Date(2022, 11, 20, 10, 0, 5, 0, time.UTC),
+					StorageRetention:    "15d",
+				},
+				Flags:    v1.FlagsResult{"storage.tsdb.retention.time": "15d", "log.level": "info"},
+				Config:   &v1.ConfigResult{YAML: "global:\n  scrape_interval: 30s\n  external_labels:\n    cluster: prod\nscrape_configs:\n- job_name: node\n"},
+				Sections: []string{FlagsSection, ConfigSection},
+			},
+			Expected: "section,name,value\nflags,storage.tsdb.retention.time,15d\nconfig,reload_success,true\nconfig,last_reload,2022-11-20T10:00:05Z\nconfig,scrape_interval,30s\nconfig,scrape_timeout,\nconfig,evaluation_interval,\nconfig,external_labels,\"{cluster=\"\"prod\"\"}\"\nconfig,scrape_configs,1\nconfig,rule_files,0\nconfig,remote_write,0\nconfig,remote_read,0\n",
+		},
+		{
+			Result: &ExemplarsResult{
+				{
+					SeriesLabels: model.LabelSet{"__name__": "http_request_duration_seconds_bucket", "le": "1"},
+					Exemplars: []v1.Exemplar{
+						{Labels: model.LabelSet{"traceID": "4bf92f3577b34da6"}, Value: 0.87, Timestamp: now},
+					},
+				},
+			},
+			Expected: fmt.Sprintf(
+				"series,trace_id,value,timestamp,labels\n\"{__name__=\"\"http_request_duration_seconds_bucket\"\", le=\"\"1\"\"}\",4bf92f3577b34da6,0.87,%s,{}\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &CompareResult{
+				{Name: "now", Vector: model.Vector{{Metric: model.Metric{"job": "api"}, Value: 1, Timestamp: now}}},
+				{Name: "now-1d", Vector: model.Vector{{Metric: model.Metric{"job": "api"}, Value: 0, Timestamp: now}}},
+				{Name: "now-1w", Vector: model.Vector{{Metric: model.Metric{"job": "api"}, Value: 4, Timestamp: now}}},
+			},
+			Expected: "job,value@now,value@now-1d,value@now-1w,delta@now-1d,delta_%@now-1d,delta@now-1w,delta_%@now-1w\napi,1,0,4,1,+Inf%,-3,-75.00%\n",
+		},
+		{
+			Result: &CacheStatsResult{
+				{Kind: "range", Entries: 2, Bytes: 4096, Expired: 1, Oldest: now.Time(), Newest: now.Time()},
+			},
+			Expected: fmt.Sprintf(
+				"kind,entries,bytes,expired,oldest,newest\nrange,2,4096,1,%s,%s\n",
+				now.Time().Format(time.RFC3339),
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &ExplainResult{Expr: mustParseExpr(`sum by (job) (rate(up{job="api"}[5m] offset 1h)) > on (job) group_left (team) team_info`)},
+			Expected: `depth,kind,detail,value_type
+0,binary_operator,">, many-to-one on (job) group_left (team)",vector
+1,aggregation,sum by (job),vector
+2,function_call,rate(),vector
+3,range_selector,[5m],matrix
+4,vector_selector,"up{job=""api""} offset 1h",vector
+1,vector_selector,team_info,vector
+`,
+		},
+		{
+			Result: &CostResult{promql.Cost{
+				Selectors: []promql.SelectorCost{
+					{Selector: `http_requests_total{code="500"}[5m]`, Series: 10, Range: 5 * time.Minute, Evaluations: 61, Samples: 12200},
+					{Selector: "up", Series: 4, Evaluations: 61, Samples: 244},
+				},
+				Samples: 12444,
+			}},
+			Expected: `selector,series,range,evaluations,samples
+"http_requests_total{code=""500""}[5m]",10,5m,61,12200
+up,4,,61,244
+`,
+		},
 	}
 	for i, c := range cases {
 		buf, err := c.Result.Csv(false)
@@ -295,6 +594,192 @@ func TestTable(t *testing.T) {
 			},
 			Expected: "METRIC       TYPE       HELP                                    UNIT\nmy_metric    counter    The best metric you've ever recorded    \n",
 		},
+		{
+			Result: &SettingsResult{
+				{Name: "host", Value: "http://localhost:9090"},
+			},
+			Expected: "SETTING    VALUE\nhost       http://localhost:9090\n",
+		},
+		{
+			Result: &ContextsResult{
+				{Name: "prod", Host: "https://prod.example.com", Current: true},
+				{Name: "staging", Host: "https://staging.example.com"},
+			},
+			Expected: "CURRENT    NAME       HOST\n*          prod       https://prod.example.com\n           staging    https://staging.example.com\n",
+		},
+		{
+			Result: &ScalarResult{
+				&model.Scalar{
+					Value:     1.0,
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("VALUE    TIMESTAMP\n1        %s\n", now.Time().Format(time.RFC3339)),
+		},
+		{
+			Result: &StringResult{
+				&model.String{
+					Value:     "hello",
+					Timestamp: now,
+				},
+			},
+			Expected: fmt.Sprintf("VALUE    TIMESTAMP\nhello    %s\n", now.Time().Format(time.RFC3339)),
+		},
+		{
+			Result: &TargetsResult{
+				{
+					Labels:             model.LabelSet{"job": "node"},
+					ScrapePool:         "node",
+					ScrapeURL:          "http://localhost:9100/metrics",
+					LastError:          "connection refused",
+					LastScrape:         now.Time(),
+					LastScrapeDuration: 0.0012,
+					Health:             v1.HealthBad,
+				},
+			},
+			Expected: fmt.Sprintf(
+				"POOL    URL                              HEALTH    LAST_SCRAPE             DURATION    LAST_ERROR            LABELS\nnode    http://localhost:9100/metrics    down      %s    1.2ms       connection refused    {job=\"node\"}\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &AlertsResult{
+				{
+					ActiveAt: now.Time(),
+					Labels:   model.LabelSet{"alertname": "TargetDown", "job": "node"},
+					State:    v1.AlertStateFiring,
+					Value:    "1e+00",
+				},
+			},
+			Expected: fmt.Sprintf(
+				"ALERTNAME     STATE     ACTIVE_SINCE            VALUE    LABELS\nTargetDown    firing    %s    1e+00    {job=\"node\"}\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &RulesResult{
+				{
+					Name: "node",
+					Rules: v1.Rules{
+						v1.AlertingRule{
+							Name:           "TargetDown",
+							Health:         v1.RuleHealthGood,
+							EvaluationTime: 0.000312,
+							LastEvaluation: now.Time(),
+							State:          "firing",
+						},
+						v1.RecordingRule{
+							Name:           "job:up:sum",
+							Health:         v1.RuleHealthBad,
+							LastError:      "bad query",
+							EvaluationTime: 0.0021,
+							LastEvaluation: now.Time(),
+						},
+					},
+				},
+			},
+			Expected: fmt.Sprintf(
+				"GROUP    NAME          TYPE         HEALTH    STATE     LAST_EVALUATION         DURATION    LAST_ERROR\nnode     TargetDown    alerting     ok        firing    %s    312µs       \nnode     job:up:sum    recording    err                 %s    2.1ms       bad query\n",
+				now.Time().Format(time.RFC3339),
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result:   &LabelNamesResult{"instance", "job"},
+			Expected: "LABELS\ninstance\njob\n",
+		},
+		{
+			Result:   &LabelValuesResult{"node", "prometheus"},
+			Expected: "VALUES\nnode\nprometheus\n",
+		},
+		{
+			Result: &SeriesResult{
+				{"__name__": "up", "job": "node"},
+				{"__name__": "up", "job": "prometheus", "env": "prod"},
+			},
+			Expected: "__NAME__    ENV     JOB\nup                  node\nup          prod    prometheus\n",
+		},
+		{
+			Result: &CardinalityResult{
+				TSDBResult: v1.TSDBResult{
+					HeadStats:               v1.TSDBHeadStats{NumSeries: 508, NumLabelPairs: 1234, ChunkCount: 937, MinTime: 1700000000000, MaxTime: 1700007200000},
+					SeriesCountByMetricName: []v1.Stat{{Name: "up", Value: 20}},
+				},
+			},
+			Expected: "HEAD_SERIES    LABEL_PAIRS    CHUNKS    MIN_TIME                MAX_TIME\n508            1234           937       2023-11-14T22:13:20Z    2023-11-15T00:13:20Z\n\n# Top metrics by series count\nMETRIC    SERIES\nup        20\n\n# Top label names by value count\nLABEL    VALUES\n\n# Top label names by memory usage\nLABEL    BYTES\n\n# Top label value pairs by series count\nPAIR    SERIES\n",
+		},
+		{
+			Result: &StatusResult{
+				Build: &v1.BuildinfoResult{Version: "2.40.1", GoVersion: "go1.19.3"},
+				Runtime: &v1.RuntimeinfoResult{
+					StartTime:        time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC),
+					StorageRetention: "15d",
+					GoroutineCount:   123,
+					GOMAXPROCS:       4,
+					CWD:              "/prometheus",
+				},
+				Now:      time.Date(2022, 11, 21, 12, 30, 0, 0, time.UTC),
+				Sections: []string{BuildSection, RuntimeSection},
+			},
+			Expected: "# Build\nNAME          VALUE\nversion       2.40.1\nrevision      \nbranch        \nbuild_user    \nbuild_date    \ngo_version    go1.19.3\n\n# Runtime\nNAME                 VALUE\nstart_time           2022-11-20T10:00:00Z\nuptime               26h30m0s\nstorage_retention    15d\nwal_corruptions      0\ngoroutines           123\ngomaxprocs           4\ngogc                 \ncwd                  /prometheus\n",
+		},
+		{
+			Result: &ExemplarsResult{
+				{
+					SeriesLabels: model.LabelSet{"__name__": "http_request_duration_seconds_bucket", "le": "1"},
+					Exemplars: []v1.Exemplar{
+						{Labels: model.LabelSet{"trace_id": "4bf92f3577b34da6", "span_id": "00f067aa"}, Value: 0.87, Timestamp: now},
+					},
+				},
+			},
+			Expected: fmt.Sprintf(
+				"SERIES                                                       TRACE_ID            VALUE    TIMESTAMP               LABELS\n{__name__=\"http_request_duration_seconds_bucket\", le=\"1\"}    4bf92f3577b34da6    0.87     %s    {span_id=\"00f067aa\"}\n",
+				now.Time().Format(time.RFC3339),
+			),
+		},
+		{
+			Result: &CompareResult{
+				{
+					Name: "now",
+					Vector: model.Vector{
+						{Metric: model.Metric{"job": "api"}, Value: 3, Timestamp: now},
+						{Metric: model.Metric{"job": "node"}, Value: 1, Timestamp: now},
+					},
+				},
+				{
+					Name: "now-1d",
+					Vector: model.Vector{
+						{Metric: model.Metric{"job": "api"}, Value: 2, Timestamp: now},
+						{Metric: model.Metric{"job": "db"}, Value: 0, Timestamp: now},
+					},
+				},
+			},
+			Expected: "JOB     VALUE@now    VALUE@now-1d    DELTA@now-1d    DELTA_%@now-1d\napi     3            2               1               +50.00%\nnode    1                                            \ndb                   0                               \n",
+		},
+		{
+			Result: &ExplainResult{Expr: mustParseExpr(`sum by (job) (rate(up{job="api"}[5m] offset 1h)) > on (job) group_left (team) team_info`)},
+			Expected: `binary_operator: >, many-to-one on (job) group_left (team) → vector
+├── aggregation: sum by (job) → vector
+│   └── function_call: rate() → vector
+│       └── range_selector: [5m] → matrix
+│           └── vector_selector: up{job="api"} offset 1h → vector
+└── vector_selector: team_info → vector
+`,
+		},
+		{
+			Result: &CostResult{promql.Cost{
+				Selectors: []promql.SelectorCost{
+					{Selector: `http_requests_total{code="500"}[5m] offset 1h`, Series: 10, Range: 5 * time.Minute, Evaluations: 61, Samples: 12200},
+					{Selector: "up", Series: 4, Evaluations: 61, Samples: 244},
+				},
+				Samples: 12444,
+			}},
+			Expected: `SELECTOR                                         SERIES    RANGE    EVALUATIONS    SAMPLES
+http_requests_total{code="500"}[5m] offset 1h    10        5m       61             12200
+up                                               4                  61             244
+TOTAL                                                                              12444
+`,
+		},
 	}
 	for i, c := range cases {
 		buf, err := c.Result.Table(false)
@@ -302,3 +787,148 @@ func TestTable(t *testing.T) {
 		assert.Equal(t, c.Expected, buf.String(), "Unexpected output for case %d", i)
 	}
 }
+
+func TestTargetsFilter(t *testing.T) {
+	targets := TargetsResult{
+		{Labels: model.LabelSet{"job": "prometheus"}, ScrapePool: "prometheus", ScrapeURL: "http://b", Health: v1.HealthGood},
+		{Labels: model.LabelSet{"job": "node"}, ScrapePool: "node", ScrapeURL: "http://b", Health: v1.HealthBad},
+		{Labels: model.LabelSet{"job": "node"}, ScrapePool: "node", ScrapeURL: "http://a", Health: v1.HealthGood},
+	}
+	cases := []struct {
+		Job      string
+		Health   string
+		Pool     string
+		Expected []string
+	}{
+		{Expected: []string{"node http://a", "node http://b", "prometheus http://b"}},
+		{Job: "node", Expected: []string{"node http://a", "node http://b"}},
+		{Health: "down", Expected: []string{"node http://b"}},
+		{Pool: "prometheus", Health: "up", Expected: []string{"prometheus http://b"}},
+		{Job: "missing", Expected: []string{}},
+	}
+	for i, c := range cases {
+		got := []string{}
+		for _, target := range targets.Filter(c.Job, c.Health, c.Pool) {
+			got = append(got, target.ScrapePool+" "+target.ScrapeURL)
+		}
+		assert.Equal(t, c.Expected, got, "Unexpected targets for case %d", i)
+	}
+	// No matches are written out as an empty list rather than null
+	empty := targets.Filter("missing", "", "")
+	buf, err := empty.Json()
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", buf.String())
+}
+
+func TestAlertsFilter(t *testing.T) {
+	alerts := AlertsResult{
+		{Labels: model.LabelSet{"alertname": "TargetDown"}, State: v1.AlertStateFiring},
+		{Labels: model.LabelSet{"alertname": "HighLatency"}, State: v1.AlertStatePending},
+	}
+	cases := []struct {
+		State    string
+		Expected []string
+	}{
+		{Expected: []string{"HighLatency", "TargetDown"}},
+		{State: "firing", Expected: []string{"TargetDown"}},
+		{State: "inactive", Expected: []string{}},
+	}
+	for i, c := range cases {
+		got := []string{}
+		for _, a := range alerts.Filter(c.State) {
+			got = append(got, string(a.Labels["alertname"]))
+		}
+		assert.Equal(t, c.Expected, got, "Unexpected alerts for case %d", i)
+	}
+	// No matches are written out as an empty list rather than null
+	empty := alerts.Filter("inactive")
+	buf, err := empty.Json()
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", buf.String())
+}
+
+func TestRulesFilter(t *testing.T) {
+	rules := RulesResult{
+		{Name: "node", Rules: v1.Rules{v1.AlertingRule{Name: "TargetDown"}, v1.RecordingRule{Name: "job:up:sum"}}},
+		{Name: "api", Rules: v1.Rules{v1.RecordingRule{Name: "job:requests:rate5m"}}},
+	}
+	cases := []struct {
+		Group    string
+		Type     string
+		Expected int
+	}{
+		{Expected: 3},
+		{Group: "node", Expected: 2},
+		{Type: AlertingRuleType, Expected: 1},
+		{Group: "api", Type: AlertingRuleType, Expected: 0},
+	}
+	for i, c := range cases {
+		filtered := rules.Filter(c.Group, c.Type)
+		assert.Len(t, filtered.ruleRows(), c.Expected, "Unexpected rules for case %d", i)
+	}
+	empty := rules.Filter("api", AlertingRuleType)
+	buf, err := empty.Json()
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", buf.String())
+}
+
+func TestCardinalityLimit(t *testing.T) {
+	r := CardinalityResult{
+		TSDBResult: v1.TSDBResult{
+			SeriesCountByMetricName:  []v1.Stat{{Name: "a", Value: 3}, {Name: "b", Value: 2}, {Name: "c", Value: 1}},
+			MemoryInBytesByLabelName: []v1.Stat{{Name: "a", Value: 3}},
+		},
+	}
+	cases := []struct {
+		Limit    int
+		Expected int
+	}{
+		{Limit: 0, Expected: 3},
+		{Limit: 2, Expected: 2},
+		{Limit: 5, Expected: 3},
+	}
+	for i, c := range cases {
+		limited := r.Limit(c.Limit)
+		assert.Len(t, limited.SeriesCountByMetricName, c.Expected, "Unexpected stats for case %d", i)
+		assert.Len(t, limited.MemoryInBytesByLabelName, 1, "Unexpected stats for case %d", i)
+	}
+}
+
+func TestExemplarsFor(t *testing.T) {
+	exemplars := ExemplarsResult{
+		{SeriesLabels: model.LabelSet{"__name__": "latency_bucket", "job": "api", "le": "1"}, Exemplars: []v1.Exemplar{{Value: 1}}},
+		{SeriesLabels: model.LabelSet{"__name__": "latency_bucket", "job": "web", "le": "1"}, Exemplars: []v1.Exemplar{{Value: 2}}},
+	}
+	cases := []struct {
+		Metric   model.Metric
+		Expected int
+	}{
+		{Metric: model.Metric{}, Expected: 2},
+		{Metric: model.Metric{"job": "api"}, Expected: 1},
+		{Metric: model.Metric{"__name__": "latency_bucket", "job": "web", "le": "1"}, Expected: 1},
+		{Metric: model.Metric{"job": "db"}, Expected: 0},
+	}
+	for i, c := range cases {
+		assert.Len(t, exemplars.For(c.Metric), c.Expected, "Unexpected exemplars for case %d", i)
+	}
+}
+
+func TestExemplarAnnotation(t *testing.T) {
+	graph := " 1.00 ┤──────\n 0.00 ┼╯     "
+	exemplars := []v1.Exemplar{
+		{Labels: model.LabelSet{"trace_id": "abc"}, Value: 1, Timestamp: model.TimeFromUnix(60)},
+		{Labels: model.LabelSet{"trace_id": "def"}, Value: 2, Timestamp: model.TimeFromUnix(600)},
+	}
+	expected := fmt.Sprintf("          ◆\n◆ %s  1  abc", model.TimeFromUnix(60).Time().Format(time.Stamp))
+	assert.Equal(t, expected, exemplarAnnotation(graph, 6, model.TimeFromUnix(0), model.TimeFromUnix(120), exemplars))
+	assert.Equal(t, "", exemplarAnnotation(graph, 6, model.TimeFromUnix(0), model.TimeFromUnix(30), exemplars))
+}
+
+// mustParseExpr parses a query for use in test cases, panicking if it's invalid
+func mustParseExpr(query string) parser.Expr {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}